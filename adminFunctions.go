@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+func handleSetUserRole(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type RoleRequest struct {
+		Role string `json:"role"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	var roleRequest RoleRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&roleRequest)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if !auth.ValidRole(roleRequest.Role) {
+		errData := makeChirpError("unknown role")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	// the new role takes effect once the user's access token is refreshed
+	user, err := cfg.db.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{
+		ID:   userID,
+		Role: roleRequest.Role,
+	})
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(convertUser(user))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
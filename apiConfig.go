@@ -1,19 +1,47 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync/atomic"
+	"time"
 
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/blobstore"
 	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/mailer"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
 	tokenSecret    string
-	polkaKey       string
+	jwtKeys        *auth.KeySet
+	passwordPolicy auth.PasswordPolicy
+	// compared against when a login names an unknown email
+	dummyPasswordHash string
+	mailer            mailer.Mailer
+	baseURL           string
+	refreshTokenKey   string
+	polkaKey          string
+	// when set, users have to confirm their email before posting chirps
+	requireVerifiedEmail bool
+	// only enable behind a proxy that sets X-Forwarded-For itself
+	trustForwardedFor bool
+	secureCookies     bool
+	// chirpy red members get longer to fix their chirps
+	chirpEditWindow    time.Duration
+	chirpEditWindowRed time.Duration
+	// where uploaded images are kept
+	blobs blobstore.BlobStore
+	// how long deleted chirps can be restored before they are purged
+	trashRetention time.Duration
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -57,3 +85,127 @@ func (cfg *apiConfig) handleReset() func(w http.ResponseWriter, r *http.Request)
 	}
 	return function
 }
+
+func (cfg *apiConfig) handleJWKS() func(w http.ResponseWriter, r *http.Request) {
+	function := func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(cfg.jwtKeys.JWKS())
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		makeJsonResponse(w, data, http.StatusOK)
+	}
+	return function
+}
+
+// authorizeRequest returns who the request is from. A request is only
+// authorized once, so a personal access token is only looked up (and its
+// last use recorded) once however many middlewares check it.
+func (cfg *apiConfig) authorizeRequest(r *http.Request) (auth.Principal, error) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err == nil {
+		return principal, nil
+	}
+	return auth.Authorize(r.Context(), r.Header, cfg.jwtKeys, cfg)
+}
+
+// middlewareRequireRole only lets a request through when its bearer token
+// belongs to a user with one of the roles.
+func (cfg *apiConfig) middlewareRequireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := cfg.authorizeRequest(r)
+		if err != nil {
+			errData := makeChirpError(err.Error())
+			makeJsonResponse(w, errData, http.StatusUnauthorized)
+			return
+		}
+		if !principal.HasRole(roles...) {
+			errData := makeChirpError("insufficient role")
+			makeJsonResponse(w, errData, http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// middlewareRequireScopes only lets a request through when its bearer token
+// was granted all of the scopes. The handler gets the principal from
+// auth.PrincipalFromContext.
+func (cfg *apiConfig) middlewareRequireScopes(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := cfg.authorizeRequest(r)
+		if err != nil {
+			errData := makeChirpError(err.Error())
+			makeJsonResponse(w, errData, http.StatusUnauthorized)
+			return
+		}
+		if !principal.HasScopes(scopes...) {
+			errData := makeChirpError("insufficient scope")
+			makeJsonResponse(w, errData, http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// middlewareRequireAdmin checks the scope as well as the role, so a personal
+// access token an admin made for something narrower can't reach admin routes.
+func (cfg *apiConfig) middlewareRequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewareRequireRole(cfg.middlewareRequireScopes(next, auth.ScopeAdmin), auth.RoleAdmin)
+}
+
+// middlewareRequireFirstParty is middlewareRequireScopes for routes that
+// show the account itself, like its sessions and tokens. OAuth2 apps are
+// turned away whatever scopes they were granted.
+func (cfg *apiConfig) middlewareRequireFirstParty(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
+	return cfg.middlewareRequireScopes(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromContext(r.Context())
+		if err != nil {
+			errData := makeChirpError(err.Error())
+			makeJsonResponse(w, errData, http.StatusUnauthorized)
+			return
+		}
+		if principal.ClientID != "" {
+			errData := makeChirpError("not available to third party apps")
+			makeJsonResponse(w, errData, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}, scopes...)
+}
+
+// LookupPersonalToken lets auth.Authorize accept personal access tokens.
+func (cfg *apiConfig) LookupPersonalToken(ctx context.Context, tokenHash string) (auth.Principal, error) {
+	token, err := cfg.db.UsePersonalAccessToken(ctx, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Principal{}, auth.ErrPersonalTokenInvalid
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.NewScopedPrincipal(token.UserID, token.Role, token.Scopes), nil
+}
+
+// middlewareCSRF rejects requests that change something and are
+// authenticated by a session cookie, unless they carry the csrf token.
+// Requests with an Authorization header can't be forged by another site
+// and pass straight through.
+func (cfg *apiConfig) middlewareCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if auth.UsesSessionCookie(r.Header) {
+			err := auth.CheckCSRF(r.Header)
+			if err != nil {
+				errData := makeChirpError(err.Error())
+				makeJsonResponse(w, errData, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/media"
+)
+
+const (
+	maxUploadBytes      = 5 << 20
+	maxChirpAttachments = 4
+	// keep it out of static/, which is served as is at /app/
+	defaultMediaDir = "media"
+	// uploads that still aren't on a chirp after this long are deleted
+	unattachedUploadRetention = time.Hour * 24
+)
+
+// Attachment is an image on a chirp.
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+}
+
+func convertAttachment(cfg *apiConfig, dbAttachment database.Attachment) Attachment {
+	return Attachment{
+		ID:           dbAttachment.ID,
+		URL:          cfg.blobs.URL(dbAttachment.BlobKey),
+		ThumbnailURL: cfg.blobs.URL(dbAttachment.ThumbnailKey),
+		ContentType:  dbAttachment.ContentType,
+		Width:        int(dbAttachment.Width),
+		Height:       int(dbAttachment.Height),
+	}
+}
+
+// attachMedia fills in the attachments of every chirp, in one query for
+// the whole page.
+func attachMedia(r *http.Request, cfg *apiConfig, chirps []*Chirp) {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	dbAttachments, err := cfg.db.GetChirpAttachments(r.Context(), ids)
+	if err != nil {
+		fmt.Println("unable to look up attachments")
+		fmt.Println(err)
+		return
+	}
+	byChirp := map[uuid.UUID][]Attachment{}
+	for _, dbAttachment := range dbAttachments {
+		chirpID := dbAttachment.ChirpID.UUID
+		byChirp[chirpID] = append(byChirp[chirpID], convertAttachment(cfg, dbAttachment))
+	}
+	for _, chirp := range chirps {
+		if attachments, ok := byChirp[chirp.ID]; ok {
+			chirp.Attachments = attachments
+		}
+	}
+}
+
+// deleteBlobs removes the files of attachments whose rows are gone. A
+// failure only leaves an unreachable file behind, so it is logged rather
+// than failing the request.
+func deleteBlobs(ctx context.Context, cfg *apiConfig, attachments []database.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.BlobKey, attachment.ThumbnailKey} {
+			err := cfg.blobs.Delete(ctx, key)
+			if err != nil {
+				fmt.Println("unable to delete blob " + key)
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+// handleUploadMedia takes an image as the raw request body. The returned
+// id can then be passed in attachment_ids when posting a chirp.
+func handleUploadMedia(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+	if !checkCanPost(w, r, cfg, userID) {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadBytes))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		errData := makeChirpError(fmt.Sprintf("uploads can be at most %d bytes", maxUploadBytes))
+		makeJsonResponse(w, errData, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// the content type the client sent is ignored, only the bytes count
+	img, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnsupportedMediaType)
+		return
+	}
+	if errors.Is(err, media.ErrTooManyPixels) {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	id := uuid.New()
+	stored := database.Attachment{
+		BlobKey:      id.String() + img.Ext,
+		ThumbnailKey: id.String() + "_thumb" + img.Ext,
+	}
+	err = cfg.blobs.Put(r.Context(), stored.BlobKey, img.ContentType, bytes.NewReader(img.Data))
+	if err == nil {
+		err = cfg.blobs.Put(r.Context(), stored.ThumbnailKey, img.ContentType, bytes.NewReader(img.Thumbnail))
+	}
+	if err != nil {
+		deleteBlobs(r.Context(), cfg, []database.Attachment{stored})
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	dbAttachment, err := cfg.db.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:           id,
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		BlobKey:      stored.BlobKey,
+		ThumbnailKey: stored.ThumbnailKey,
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		SizeBytes:    int32(len(img.Data)),
+	})
+	if err != nil {
+		deleteBlobs(r.Context(), cfg, []database.Attachment{stored})
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	data, err = json.Marshal(convertAttachment(cfg, dbAttachment))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+// purgeUnattachedUploads deletes a batch of uploads that were never put on
+// a chirp. It returns how many it purged, fewer than purgeBatchSize once
+// there are none left.
+func purgeUnattachedUploads(ctx context.Context, cfg *apiConfig) (int, error) {
+	attachments, err := cfg.db.DeleteUnattachedAttachments(ctx, database.DeleteUnattachedAttachmentsParams{
+		Cutoff:   time.Now().UTC().Add(-unattachedUploadRetention),
+		RowLimit: purgeBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	deleteBlobs(ctx, cfg, attachments)
+	return len(attachments), nil
+}
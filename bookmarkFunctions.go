@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+func markBookmarkedChirps(r *http.Request, cfg *apiConfig, viewer uuid.NullUUID, chirps []Chirp) {
+	if !viewer.Valid || len(chirps) == 0 {
+		return
+	}
+	chirpIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+	ids, err := cfg.db.GetBookmarkedChirpIDs(r.Context(), database.GetBookmarkedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		fmt.Println("unable to look up bookmarked chirps")
+		fmt.Println(err)
+		return
+	}
+	bookmarked := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		bookmarked[id] = true
+	}
+	for i := range chirps {
+		chirps[i].BookmarkedByMe = bookmarked[chirps[i].ID]
+	}
+}
+
+func handleAddBookmark(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type BookmarkRequest struct {
+		ChirpID uuid.UUID `json:"chirp_id"`
+	}
+
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var bookmark BookmarkRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&bookmark)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpById(r.Context(), bookmark.ChirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	// bookmarking a chirp twice is not an error, it just stays bookmarked
+	added, err := cfg.db.AddBookmark(r.Context(), database.AddBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirps := []Chirp{convertChirp(chirp)}
+	decorateChirps(r, cfg, chirps)
+	chirps[0].BookmarkedByMe = true
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if added > 0 {
+		status = http.StatusCreated
+	}
+	makeJsonResponse(w, data, status)
+}
+
+func handleDeleteBookmark(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	_, err = cfg.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetBookmarks lists the user's own bookmarks, most recently
+// bookmarked first, a page at a time like GET /api/chirps.
+func handleGetBookmarks(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the cursor is the time of the bookmark, not of the chirp
+	rows, err := cfg.db.GetUserBookmarks(r.Context(), database.GetUserBookmarksParams{
+		UserID:             userID,
+		BeforeBookmarkedAt: page.afterCreatedAt,
+		BeforeChirpID:      page.afterID,
+		RowLimit:           int32(page.limit + 1),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	bookmarks := ChirpPage{Chirps: []Chirp{}}
+	if len(rows) > page.limit {
+		rows = rows[:page.limit]
+		last := rows[len(rows)-1]
+		bookmarks.NextCursor = encodeChirpCursor(database.Chirp{ID: last.Chirp.ID, CreatedAt: last.BookmarkedAt})
+	}
+	for _, row := range rows {
+		bookmarks.Chirps = append(bookmarks.Chirps, convertChirp(row.Chirp))
+	}
+	decorateChirps(r, cfg, bookmarks.Chirps)
+
+	data, err := json.Marshal(bookmarks)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
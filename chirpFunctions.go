@@ -2,50 +2,180 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mrjkey/chirpy/internal/auth"
 	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/paging"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Edited    bool       `json:"edited"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	ThreadID  uuid.UUID  `json:"thread_id"`
+	LikeCount int        `json:"like_count"`
+	// whether the user making the request has liked or bookmarked it
+	LikedByMe      bool `json:"liked_by_me"`
+	BookmarkedByMe bool `json:"bookmarked_by_me"`
+	// pinned to the top of its author's chirps, only set in that listing
+	Pinned       bool          `json:"pinned"`
+	RechirpCount int           `json:"rechirp_count"`
+	QuoteCount   int           `json:"quote_count"`
+	Reshare      *ChirpReshare `json:"reshare"`
+	Attachments  []Attachment  `json:"attachments"`
+	Poll         *Poll         `json:"poll"`
+	// a deleted chirp, only shown in a thread that has replies under it
+	Deleted bool `json:"deleted"`
 }
 
 func convertChirp(dbChirp database.Chirp) Chirp {
 	chirp := Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
+		ID:           dbChirp.ID,
+		CreatedAt:    dbChirp.CreatedAt,
+		UpdatedAt:    dbChirp.UpdatedAt,
+		Body:         dbChirp.Body,
+		UserID:       dbChirp.UserID,
+		Edited:       dbChirp.EditedAt.Valid,
+		ThreadID:     dbChirp.ThreadID,
+		LikeCount:    int(dbChirp.LikeCount),
+		RechirpCount: int(dbChirp.RechirpCount),
+		QuoteCount:   int(dbChirp.QuoteCount),
+		Deleted:      dbChirp.DeletedAt.Valid,
+		Attachments:  []Attachment{},
+	}
+	if dbChirp.InReplyTo.Valid {
+		chirp.InReplyTo = &dbChirp.InReplyTo.UUID
+	}
+	if dbChirp.DeletedAt.Valid {
+		// nothing of a deleted chirp shows but where it sat
+		chirp.Body = ""
+		return chirp
+	}
+	if dbChirp.ReshareKind.Valid {
+		chirp.Reshare = &ChirpReshare{Kind: dbChirp.ReshareKind.String}
+		if dbChirp.ReshareOf.Valid {
+			chirp.Reshare.ChirpID = &dbChirp.ReshareOf.UUID
+		}
 	}
 	return chirp
 }
 
-func handleAddChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	tokenString, err := auth.GetBearerToken(r.Header)
+// decorateChirps fills in what depends on other rows or on who is asking.
+func decorateChirps(r *http.Request, cfg *apiConfig, chirps []Chirp) {
+	viewer := viewerID(r, cfg)
+	markLikedChirps(r, cfg, viewer, chirps)
+	markBookmarkedChirps(r, cfg, viewer, chirps)
+	attachReshares(r, cfg, chirps)
+
+	// reshared chirps show their images and polls too
+	all := []*Chirp{}
+	for i := range chirps {
+		if chirps[i].Deleted {
+			continue
+		}
+		all = append(all, &chirps[i])
+		if chirps[i].Reshare != nil && chirps[i].Reshare.Chirp != nil {
+			all = append(all, chirps[i].Reshare.Chirp)
+		}
+	}
+	attachMedia(r, cfg, all)
+	attachPolls(r, cfg, viewer, all)
+}
+
+// checkCanPost writes an error and returns false if the user may not
+// post yet.
+func checkCanPost(w http.ResponseWriter, r *http.Request, cfg *apiConfig, userID uuid.UUID) bool {
+	if !cfg.requireVerifiedEmail {
+		return true
+	}
+	user, err := cfg.db.GetUserById(r.Context(), userID)
 	if err != nil {
 		quickChirpError(w, err.Error())
-		return
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		errData := makeChirpError(errEmailNotVerified.Error())
+		makeJsonResponse(w, errData, http.StatusForbidden)
+		return false
 	}
-	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	return true
+}
+
+var errEmailNotVerified = errors.New("email address must be verified before posting")
+
+var (
+	errReplyNotFound  = errors.New("the chirp being replied to does not exist")
+	errReplyToRechirp = errors.New("reply to the original chirp, not the rechirp")
+)
+
+// linkChirp checks the chirps a new chirp replies to and quotes, and
+// fills them in on args.
+func linkChirp(ctx context.Context, cfg *apiConfig, args *database.AddChirpParams, inReplyTo, quoteOf *uuid.UUID) error {
+	if inReplyTo != nil {
+		parent, err := cfg.db.GetChirpById(ctx, *inReplyTo)
+		if errors.Is(err, sql.ErrNoRows) || parent.DeletedAt.Valid {
+			return errReplyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if parent.ReshareKind.String == reshareRechirp {
+			return errReplyToRechirp
+		}
+		args.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+	if quoteOf != nil {
+		quoted, err := reshareTarget(ctx, cfg, *quoteOf)
+		if err != nil {
+			return err
+		}
+		args.ReshareOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		args.ReshareKind = sql.NullString{String: reshareQuote, Valid: true}
+	}
+	return nil
+}
+
+// isLinkError reports whether linkChirp failed because of what the user
+// asked for, rather than the database.
+func isLinkError(err error) bool {
+	return errors.Is(err, errReplyNotFound) || errors.Is(err, errReplyToRechirp) || errors.Is(err, errReshareNotFound)
+}
+
+func handleAddChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type ChirpRequest struct {
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
+		// uploaded with POST /api/media, in the order they should show
+		AttachmentIDs []uuid.UUID  `json:"attachment_ids"`
+		Poll          *PollRequest `json:"poll"`
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		errData := makeChirpError(err.Error())
 		makeJsonResponse(w, errData, http.StatusUnauthorized)
 		return
 	}
+	userID := principal.UserID
+
+	if !checkCanPost(w, r, cfg, userID) {
+		return
+	}
 
 	decoder := json.NewDecoder(r.Body)
-	chirp := Chirp{}
+	chirp := ChirpRequest{}
 	err = decoder.Decode(&chirp)
 	if err != nil {
 		quickChirpError(w, err.Error())
@@ -57,73 +187,217 @@ func handleAddChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		quickChirpError(w, err.Error())
 		return
 	}
+	if len(chirp.AttachmentIDs) > maxChirpAttachments {
+		errData := makeChirpError(fmt.Sprintf("a chirp can have at most %d attachments", maxChirpAttachments))
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if chirp.Poll != nil {
+		err = chirp.Poll.validate()
+		if err != nil {
+			errData := makeChirpError(err.Error())
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+	}
 
 	args := database.AddChirpParams{
 		Body:   body,
 		UserID: userID,
 	}
-
-	dbChirp, err := cfg.db.AddChirp(r.Context(), args)
+	err = linkChirp(r.Context(), cfg, &args, chirp.InReplyTo, chirp.QuoteOf)
+	if isLinkError(err) {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
 	}
-	data, err := json.Marshal(convertChirp(dbChirp))
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
 	}
-	makeJsonResponse(w, data, http.StatusCreated)
-}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-func handleGetChirps(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	authorIdString := r.URL.Query().Get("author_id")
-	sortString := r.URL.Query().Get("sort")
-	// fmt.Println(authorIdString)
-	var chirps []database.Chirp
-	var err error
-	if authorIdString != "" {
-		// fmt.Println("found author id")
-		authorId, err := uuid.Parse(authorIdString)
+	dbChirp, err := qtx.AddChirp(r.Context(), args)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = indexChirpText(r.Context(), qtx, dbChirp.ID, dbChirp.Body)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	for position, attachmentID := range chirp.AttachmentIDs {
+		attached, err := qtx.AttachToChirp(r.Context(), database.AttachToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+			Position: int32(position),
+			ID:       attachmentID,
+			UserID:   userID,
+		})
 		if err != nil {
 			quickChirpError(w, err.Error())
 			return
 		}
-		chirps, err = cfg.db.GetAllChirpsByAuthor(context.Background(), authorId)
-		if err != nil {
-			quickChirpError(w, err.Error())
+		// someone else's upload, one already on a chirp, or the same id
+		// twice
+		if attached == 0 {
+			errData := makeChirpError("attachment " + attachmentID.String() + " not found")
+			makeJsonResponse(w, errData, http.StatusBadRequest)
 			return
 		}
-	} else {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
+	}
+	if chirp.Poll != nil {
+		err = createPoll(r.Context(), qtx, dbChirp.ID, *chirp.Poll)
 		if err != nil {
 			quickChirpError(w, err.Error())
 			return
 		}
 	}
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
 
-	if sortString == "desc" {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-		})
+	chirps := []Chirp{convertChirp(dbChirp)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
 	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+// ChirpPage is one page of a chirp listing. NextCursor is empty on the
+// last page.
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+func encodeChirpCursor(chirp database.Chirp) string {
+	return paging.Cursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}.Encode()
+}
 
-	respChirps := []Chirp{}
+// chirpPageRequest is the limit and cursor query parameters shared by
+// every chirp listing, ready to pass to the queries.
+type chirpPageRequest struct {
+	limit          int
+	afterCreatedAt sql.NullTime
+	afterID        uuid.NullUUID
+	afterRank      sql.NullFloat64
+}
+
+// parseChirpPageRequest writes an error and returns false if the limit or
+// cursor are no good.
+func parseChirpPageRequest(w http.ResponseWriter, r *http.Request) (chirpPageRequest, bool) {
+	parsed, err := paging.ParseRequest(r.URL.Query())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return chirpPageRequest{}, false
+	}
+
+	page := chirpPageRequest{limit: parsed.Limit}
+	if cursor := parsed.After; cursor != nil {
+		page.afterCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		page.afterID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		if cursor.Rank != nil {
+			page.afterRank = sql.NullFloat64{Float64: float64(*cursor.Rank), Valid: true}
+		}
+	}
+	return page, true
+}
+
+// writeChirpPage sends up to limit chirps, and a cursor if the query found
+// more than that. Pinned chirps go ahead of them and don't count towards
+// the limit.
+func writeChirpPage(w http.ResponseWriter, r *http.Request, cfg *apiConfig, pinned, chirps []database.Chirp, limit int) {
+	page := ChirpPage{Chirps: []Chirp{}}
+	if len(chirps) > limit {
+		chirps = chirps[:limit]
+		page.NextCursor = encodeChirpCursor(chirps[len(chirps)-1])
+	}
+	for _, chirp := range pinned {
+		pinnedChirp := convertChirp(chirp)
+		pinnedChirp.Pinned = true
+		page.Chirps = append(page.Chirps, pinnedChirp)
+	}
 	for _, chirp := range chirps {
-		respChirps = append(respChirps, convertChirp(chirp))
+		page.Chirps = append(page.Chirps, convertChirp(chirp))
 	}
+	decorateChirps(r, cfg, page.Chirps)
 
-	data, err := json.Marshal(respChirps)
+	data, err := json.Marshal(page)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
 	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+func handleGetChirps(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	query := r.URL.Query()
 
-	w.Write(data)
+	authorID := uuid.NullUUID{}
+	if authorIdString := query.Get("author_id"); authorIdString != "" {
+		parsed, err := uuid.Parse(authorIdString)
+		if err != nil {
+			errData := makeChirpError(err.Error())
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// one extra row tells us whether there is another page
+	var chirps []database.Chirp
+	var err error
+	if query.Get("sort") == "desc" {
+		chirps, err = cfg.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:       authorID,
+			AfterCreatedAt: page.afterCreatedAt,
+			AfterID:        page.afterID,
+			RowLimit:       int32(page.limit + 1),
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:       authorID,
+			AfterCreatedAt: page.afterCreatedAt,
+			AfterID:        page.afterID,
+			RowLimit:       int32(page.limit + 1),
+		})
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
 
+	// an author's pinned chirps lead the first page of their chirps
+	var pinned []database.Chirp
+	if authorID.Valid && !page.afterCreatedAt.Valid {
+		pinned, err = cfg.db.ListPinnedChirps(r.Context(), authorID.UUID)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+	}
+
+	writeChirpPage(w, r, cfg, pinned, chirps, page.limit)
 }
 
 func handlGetChirpById(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
@@ -140,8 +414,16 @@ func handlGetChirpById(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		makeJsonResponse(w, errData, http.StatusNotFound)
 		return
 	}
+	if chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
 
-	data, err := json.Marshal(Chirp(chirp))
+	chirps := []Chirp{convertChirp(chirp)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
 	if err != nil {
 		errData := makeChirpError(err.Error())
 		makeJsonResponse(w, errData, http.StatusNotFound)
@@ -154,7 +436,7 @@ func handlGetChirpById(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 }
 
 func handleDeleteChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	userID, err := auth.AuthorizeUser(r.Header, cfg.tokenSecret)
+	principal, err := auth.PrincipalFromContext(r.Context())
 	if err != nil {
 		errData := makeChirpError(err.Error())
 		makeJsonResponse(w, errData, http.StatusUnauthorized)
@@ -168,25 +450,134 @@ func handleDeleteChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		makeJsonResponse(w, errData, http.StatusNotFound)
 		return
 	}
-	chirp, err := cfg.db.GetChirpById(r.Context(), parsedId)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		errData := makeChirpError(err.Error())
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// locking the chirp stops a reply being added while this runs
+	chirp, err := qtx.GetChirpByIdForUpdate(r.Context(), parsedId)
+	if err != nil || chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
 		makeJsonResponse(w, errData, http.StatusNotFound)
 		return
 	}
 
-	if chirp.UserID != userID {
+	// moderators can take down anyone's chirps
+	if chirp.UserID != principal.UserID && !principal.HasScopes(auth.ScopeChirpsModerate) {
 		errData := makeChirpError("user is not the author")
 		makeJsonResponse(w, errData, http.StatusForbidden)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), chirp.ID)
+	// a plain rechirp has nothing to restore, undoing it is rechirping again
+	if chirp.ReshareKind.String == reshareRechirp {
+		err = qtx.DeleteChirp(r.Context(), chirp.ID)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+	} else {
+		// the chirp is only marked, and is purged once it has been in the
+		// trash for the retention window
+		deletedBy := uuid.NullUUID{UUID: principal.UserID, Valid: true}
+		deletedAt, err := qtx.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{
+			ID:        chirp.ID,
+			DeletedBy: deletedBy,
+		})
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		err = qtx.SoftDeleteRechirpsOf(r.Context(), database.SoftDeleteRechirpsOfParams{
+			ReshareOf: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			DeletedAt: deletedAt,
+			DeletedBy: deletedBy,
+		})
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		// it comes back from the trash unpinned, so it can't go over the limit
+		err = qtx.DeletePin(r.Context(), chirp.ID)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ThreadChirp is a chirp in a conversation, Depth replies below the start.
+type ThreadChirp struct {
+	Chirp
+	Depth int `json:"depth"`
+}
+
+func handleGetChirpThread(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		errData := makeChirpError(err.Error())
 		makeJsonResponse(w, errData, http.StatusNotFound)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	rows, err := cfg.db.GetChirpThread(r.Context(), chirpID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	// a deleted chirp keeps its place while there are replies under it
+	// to show. rows come parent first, so walking back sees every reply
+	// before the chirp it replies to
+	shown := make([]bool, len(rows))
+	hasShownReplies := map[uuid.UUID]bool{}
+	for i := len(rows) - 1; i >= 0; i-- {
+		chirp := rows[i].Chirp
+		shown[i] = !chirp.DeletedAt.Valid || hasShownReplies[chirp.ID]
+		if shown[i] && chirp.InReplyTo.Valid {
+			hasShownReplies[chirp.InReplyTo.UUID] = true
+		}
+	}
+
+	thread := []ThreadChirp{}
+	for i, row := range rows {
+		if !shown[i] {
+			continue
+		}
+		chirp := convertChirp(row.Chirp)
+		thread = append(thread, ThreadChirp{Chirp: chirp, Depth: int(row.Depth)})
+	}
+
+	if len(thread) == 0 {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	chirps := []Chirp{}
+	for _, threadChirp := range thread {
+		chirps = append(chirps, threadChirp.Chirp)
+	}
+	decorateChirps(r, cfg, chirps)
+	for i := range thread {
+		thread[i].Chirp = chirps[i]
+	}
+
+	data, err := json.Marshal(thread)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
 }
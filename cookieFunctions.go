@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mrjkey/chirpy/internal/auth"
+)
+
+// setSessionCookies hands the tokens to a browser in cookies its javascript
+// can't read, along with a fresh csrf token it can.
+func setSessionCookies(w http.ResponseWriter, cfg *apiConfig, accessToken, refreshToken string) error {
+	csrfToken, err := auth.MakeCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.AccessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		MaxAge:   int(time.Hour.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// the refresh token is only needed by /api/refresh and /api/revoke
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.RefreshTokenCookie,
+		Value:    refreshToken,
+		Path:     "/api/",
+		MaxAge:   int(refreshTokenDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CSRFCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   int(refreshTokenDuration.Seconds()),
+		Secure:   cfg.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+func clearSessionCookies(w http.ResponseWriter, cfg *apiConfig) {
+	cookies := []struct {
+		name string
+		path string
+	}{
+		{auth.AccessTokenCookie, "/"},
+		{auth.RefreshTokenCookie, "/api/"},
+		{auth.CSRFCookie, "/"},
+	}
+	for _, cookie := range cookies {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			MaxAge:   -1,
+			HttpOnly: cookie.name != auth.CSRFCookie,
+			Secure:   cfg.secureCookies,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
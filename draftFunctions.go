@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	defaultSchedulerInterval = time.Second * 30
+	maxScheduleAhead         = time.Hour * 24 * 365
+)
+
+// Draft is a chirp that hasn't been posted yet. It is scheduled when
+// PublishAt is set.
+type Draft struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	QuoteOf   *uuid.UUID `json:"quote_of"`
+	PublishAt *time.Time `json:"publish_at"`
+	// draft or scheduled
+	Status string `json:"status"`
+	// why it couldn't be published when it was due
+	LastError *string `json:"last_error"`
+}
+
+type DraftRequest struct {
+	Body      string     `json:"body"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	QuoteOf   *uuid.UUID `json:"quote_of"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func convertDraft(dbDraft database.ChirpDraft) Draft {
+	draft := Draft{
+		ID:        dbDraft.ID,
+		CreatedAt: dbDraft.CreatedAt,
+		UpdatedAt: dbDraft.UpdatedAt,
+		Body:      dbDraft.Body,
+		Status:    "draft",
+	}
+	if dbDraft.InReplyTo.Valid {
+		draft.InReplyTo = &dbDraft.InReplyTo.UUID
+	}
+	if dbDraft.QuoteOf.Valid {
+		draft.QuoteOf = &dbDraft.QuoteOf.UUID
+	}
+	if dbDraft.PublishAt.Valid {
+		draft.PublishAt = &dbDraft.PublishAt.Time
+		draft.Status = "scheduled"
+	}
+	if dbDraft.LastError.Valid {
+		draft.LastError = &dbDraft.LastError.String
+	}
+	return draft
+}
+
+// checkDraftRequest validates a draft the way a chirp is validated when
+// posted, so a scheduled chirp only fails later if what it links to goes
+// away. It writes an error and returns false if the draft is no good.
+func checkDraftRequest(w http.ResponseWriter, r *http.Request, cfg *apiConfig, draftRequest DraftRequest) (database.AddChirpParams, sql.NullTime, bool) {
+	body, err := validateChirp(draftRequest.Body)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return database.AddChirpParams{}, sql.NullTime{}, false
+	}
+
+	args := database.AddChirpParams{Body: body}
+	err = linkChirp(r.Context(), cfg, &args, draftRequest.InReplyTo, draftRequest.QuoteOf)
+	if isLinkError(err) {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return database.AddChirpParams{}, sql.NullTime{}, false
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return database.AddChirpParams{}, sql.NullTime{}, false
+	}
+
+	publishAt := sql.NullTime{}
+	if draftRequest.PublishAt != nil {
+		untilPublish := time.Until(*draftRequest.PublishAt)
+		if untilPublish <= 0 || untilPublish > maxScheduleAhead {
+			errData := makeChirpError("publish_at must be in the future and within a year")
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return database.AddChirpParams{}, sql.NullTime{}, false
+		}
+		publishAt = sql.NullTime{Time: draftRequest.PublishAt.UTC(), Valid: true}
+	}
+	return args, publishAt, true
+}
+
+func handleCreateDraft(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var draftRequest DraftRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&draftRequest)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	// scheduling is posting, just later
+	if draftRequest.PublishAt != nil && !checkCanPost(w, r, cfg, userID) {
+		return
+	}
+
+	args, publishAt, ok := checkDraftRequest(w, r, cfg, draftRequest)
+	if !ok {
+		return
+	}
+
+	dbDraft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:    userID,
+		Body:      args.Body,
+		InReplyTo: args.InReplyTo,
+		QuoteOf:   args.ReshareOf,
+		PublishAt: publishAt,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	data, err := json.Marshal(convertDraft(dbDraft))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+func handleGetDrafts(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	dbDrafts, err := cfg.db.GetDrafts(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	drafts := []Draft{}
+	for _, dbDraft := range dbDrafts {
+		drafts = append(drafts, convertDraft(dbDraft))
+	}
+
+	data, err := json.Marshal(drafts)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleGetDraft(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	dbDraft, err := cfg.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		errData := makeChirpError("draft not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(convertDraft(dbDraft))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+// handleUpdateDraft replaces a draft. Setting publish_at schedules or
+// reschedules it, leaving it out turns it back into a plain draft.
+func handleUpdateDraft(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	var draftRequest DraftRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&draftRequest)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if draftRequest.PublishAt != nil && !checkCanPost(w, r, cfg, userID) {
+		return
+	}
+
+	args, publishAt, ok := checkDraftRequest(w, r, cfg, draftRequest)
+	if !ok {
+		return
+	}
+
+	// waits for the scheduler if it is publishing this draft right now,
+	// and then finds it gone
+	dbDraft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:        draftID,
+		UserID:    userID,
+		Body:      args.Body,
+		InReplyTo: args.InReplyTo,
+		QuoteOf:   args.ReshareOf,
+		PublishAt: publishAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		errData := makeChirpError("draft not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	data, err := json.Marshal(convertDraft(dbDraft))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleDeleteDraft(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if deleted == 0 {
+		errData := makeChirpError("draft not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishDraft posts a draft as a chirp and removes it, in the caller's
+// transaction. A link error means what it replies to or quotes has gone
+// since it was written.
+func publishDraft(ctx context.Context, cfg *apiConfig, qtx *database.Queries, draft database.ChirpDraft) (database.Chirp, error) {
+	args := database.AddChirpParams{
+		Body:   draft.Body,
+		UserID: draft.UserID,
+	}
+	var inReplyTo, quoteOf *uuid.UUID
+	if draft.InReplyTo.Valid {
+		inReplyTo = &draft.InReplyTo.UUID
+	}
+	if draft.QuoteOf.Valid {
+		quoteOf = &draft.QuoteOf.UUID
+	}
+	// the author may have changed their email since scheduling it
+	if cfg.requireVerifiedEmail {
+		user, err := qtx.GetUserById(ctx, draft.UserID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		if !user.EmailVerifiedAt.Valid {
+			return database.Chirp{}, errEmailNotVerified
+		}
+	}
+	err := linkChirp(ctx, cfg, &args, inReplyTo, quoteOf)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	chirp, err := qtx.AddChirp(ctx, args)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	err = indexChirpText(ctx, qtx, chirp.ID, chirp.Body)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	_, err = qtx.DeleteDraft(ctx, database.DeleteDraftParams{
+		ID:     draft.ID,
+		UserID: draft.UserID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// handlePublishDraft posts a draft straight away, scheduled or not.
+func handlePublishDraft(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+	if !checkCanPost(w, r, cfg, userID) {
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// the lock keeps the scheduler from publishing it a second time
+	draft, err := qtx.GetDraftForUpdate(r.Context(), database.GetDraftForUpdateParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		errData := makeChirpError("draft not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	dbChirp, err := publishDraft(r.Context(), cfg, qtx, draft)
+	if isLinkError(err) {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errEmailNotVerified) {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirps := []Chirp{convertChirp(dbChirp)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+// runScheduler publishes scheduled drafts as they come due and purges
+// chirps that have been in the trash too long, until ctx is done. It also
+// deletes uploads that never made it onto a chirp. Every server runs one.
+// Each draft is claimed with a row lock that is held until it has been
+// published and deleted, so however many schedulers there are, each draft
+// is posted once.
+func runScheduler(ctx context.Context, cfg *apiConfig, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			published, err := publishNextDraft(ctx, cfg)
+			if err != nil {
+				fmt.Println("unable to publish scheduled chirp")
+				fmt.Println(err)
+			}
+			if !published {
+				break
+			}
+		}
+		for {
+			purged, err := purgeDeletedChirps(ctx, cfg)
+			if err != nil {
+				fmt.Println("unable to purge deleted chirps")
+				fmt.Println(err)
+			}
+			if purged < purgeBatchSize {
+				break
+			}
+		}
+		for {
+			purged, err := purgeUnattachedUploads(ctx, cfg)
+			if err != nil {
+				fmt.Println("unable to purge unattached uploads")
+				fmt.Println(err)
+			}
+			if purged < purgeBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishNextDraft publishes one due draft in its own transaction, so a
+// draft that fails doesn't hold up the rest. It returns false once there
+// are none left to do.
+func publishNextDraft(ctx context.Context, cfg *apiConfig) (bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	drafts, err := qtx.ClaimDueDrafts(ctx, 1)
+	if err != nil || len(drafts) == 0 {
+		return false, err
+	}
+	draft := drafts[0]
+
+	// a failed statement spoils the rest of the transaction, the savepoint
+	// lets the draft be marked failed while it is still locked
+	_, err = tx.ExecContext(ctx, "savepoint publish_draft")
+	if err != nil {
+		return false, err
+	}
+	_, err = publishDraft(ctx, cfg, qtx, draft)
+	if err != nil && !isTransientError(err) {
+		// it won't publish as it is however often it's tried, so it goes
+		// back to the author's drafts with the reason instead of staying
+		// at the front of the queue
+		publishErr := err
+		_, err = tx.ExecContext(ctx, "rollback to savepoint publish_draft")
+		if err == nil {
+			err = qtx.FailDraft(ctx, database.FailDraftParams{
+				ID:        draft.ID,
+				LastError: sql.NullString{String: publishErr.Error(), Valid: true},
+			})
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isTransientError reports whether a publish failed because of the database
+// rather than the draft, so trying again later may work.
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		// connection problems, serialization failures and deadlocks,
+		// running out of resources, and the server shutting down
+		case "08", "40", "53", "57":
+			return true
+		}
+	}
+	return false
+}
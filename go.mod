@@ -9,3 +9,5 @@ require (
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.36.0
 )
+
+require golang.org/x/sys v0.31.0 // indirect
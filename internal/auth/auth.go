@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,51 +15,122 @@ import (
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), 1)
+// Claims are the claims in every token Chirpy signs.
+type Claims struct {
+	Role  string `json:"role,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	// set on tokens issued to a third party app through OAuth2
+	ClientID string `json:"client_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func MakeJWT(principal Principal, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return signClaims(Claims{
+		Role:     principal.Role,
+		Scope:    JoinScopes(principal.Scopes),
+		ClientID: principal.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn).UTC()),
+			Subject:   principal.UserID.String(),
+		},
+	}, keys)
+}
+
+func signClaims(claims jwt.Claims, keys *KeySet) (string, error) {
+	key := keys.Current()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	signedString, err := token.SignedString(key.signKey)
+	return signedString, err
+}
+
+func ValidateJWT(tokenString string, keys *KeySet) (Principal, error) {
+	claims, err := parseJWT(tokenString, keys)
 	if err != nil {
-		return "", err
+		return Principal{}, err
+	}
+
+	// an mfa challenge is signed with the same keys but only proves the
+	// password was right, it must not work as an access token
+	for _, audience := range claims.Audience {
+		if audience == mfaChallengeAudience {
+			return Principal{}, fmt.Errorf("mfa challenge cannot be used as an access token")
+		}
 	}
-	return string(hashedPass), nil
-}
 
-func CheckPasswordHash(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err
+	userID, err := subjectUserID(claims)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{
+		UserID:   userID,
+		Role:     claims.Role,
+		Scopes:   SplitScopes(claims.Scope),
+		ClientID: claims.ClientID,
+	}, nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+const mfaChallengeAudience = "chirpy-mfa"
+
+// MakeMFAChallenge issues the short lived token handed out by login when a
+// second factor is still needed.
+func MakeMFAChallenge(userID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return signClaims(jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn).UTC()),
 		Subject:   userID.String(),
-	})
-	signedString, err := token.SignedString([]byte(tokenSecret))
-	return signedString, err
+		Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+	}, keys)
+}
+
+func ValidateMFAChallenge(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	claims, err := parseJWT(tokenString, keys)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if !claims.VerifyAudience(mfaChallengeAudience, true) {
+		return uuid.UUID{}, fmt.Errorf("token is not an mfa challenge")
+	}
+	return subjectUserID(claims)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+func parseJWT(tokenString string, keys *KeySet) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
-		&jwt.RegisteredClaims{},
+		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
-			// Return the key used to sign the token
-			return []byte(tokenSecret), nil
+			kid, _ := token.Header["kid"].(string)
+			key, ok := keys.Lookup(kid)
+			if !ok {
+				return nil, fmt.Errorf("unknown signing key %v", kid)
+			}
+			// the header must not be able to pick a different algorithm
+			// than the one the key belongs to
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Method.Alg())
+			}
+			return key.verifyKey, nil
 		},
+		jwt.WithValidMethods(keys.Algorithms()),
 	)
 	if err != nil {
-		return uuid.UUID{}, err
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok {
-		return uuid.UUID{}, fmt.Errorf("invalid token claims")
+		return nil, fmt.Errorf("invalid token claims")
 	}
+	return claims, nil
+}
 
+func subjectUserID(claims *Claims) (uuid.UUID, error) {
 	userIDStr := claims.Subject
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
@@ -64,10 +140,31 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// GetBearerToken returns the token from the Authorization header, or from
+// the access token cookie for browsers using a cookie session.
 func GetBearerToken(headers http.Header) (string, error) {
 	token := headers.Get("Authorization")
 	if token == "" {
-		return "", fmt.Errorf("token string not found in header")
+		cookie, err := getCookie(headers, AccessTokenCookie)
+		if err != nil {
+			return "", fmt.Errorf("token string not found in header")
+		}
+		return cookie, nil
+	}
+	stripped := strings.Replace(token, "Bearer ", "", 1)
+	return stripped, nil
+}
+
+// GetRefreshToken is GetBearerToken for the refresh and revoke endpoints,
+// which fall back to the refresh token cookie instead.
+func GetRefreshToken(headers http.Header) (string, error) {
+	token := headers.Get("Authorization")
+	if token == "" {
+		cookie, err := getCookie(headers, RefreshTokenCookie)
+		if err != nil {
+			return "", fmt.Errorf("token string not found in header")
+		}
+		return cookie, nil
 	}
 	stripped := strings.Replace(token, "Bearer ", "", 1)
 	return stripped, nil
@@ -86,18 +183,85 @@ func MakeRefreshToken() (string, error) {
 	return output, nil
 }
 
-func AuthorizeUser(headers http.Header, tokenSecret string) (uuid.UUID, error) {
+const refreshTokenPrefixLength = 8
+
+// HashRefreshToken returns the keyed hash that is stored in place of the
+// refresh token, so a copy of the database cannot be used to log in.
+func HashRefreshToken(token, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RefreshTokenPrefix returns the first few characters of a refresh token,
+// enough for a person to tell tokens apart without being able to use them.
+func RefreshTokenPrefix(token string) string {
+	if len(token) < refreshTokenPrefixLength {
+		return token
+	}
+	return token[:refreshTokenPrefixLength]
+}
+
+var (
+	ErrRefreshTokenExpired = errors.New("refresh token is expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// CheckRefreshToken reports whether a stored refresh token may still be
+// exchanged. A token that was already revoked is treated as reuse, since
+// rotation revokes every token as soon as it is spent.
+func CheckRefreshToken(expiredAt time.Time, revoked bool) error {
+	if revoked {
+		return ErrRefreshTokenReused
+	}
+	if expiredAt.Before(time.Now()) {
+		return ErrRefreshTokenExpired
+	}
+	return nil
+}
+
+// MakeEmailToken returns a random token for links sent by email, like
+// password resets. Only its hash, from HashEmailToken, should be stored.
+func MakeEmailToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authorize validates the bearer token in the headers and returns who it
+// was issued to along with their role and scopes. The token can be a JWT or
+// a personal access token, which is looked up in tokens.
+func Authorize(ctx context.Context, headers http.Header, keys *KeySet, tokens PersonalTokenStore) (Principal, error) {
 	tokenString, err := GetBearerToken(headers)
 	if err != nil {
-		return uuid.UUID{}, err
+		return Principal{}, err
+	}
+
+	if IsPersonalToken(tokenString) {
+		if tokens == nil {
+			return Principal{}, ErrPersonalTokenInvalid
+		}
+		return tokens.LookupPersonalToken(ctx, HashPersonalToken(tokenString))
 	}
 
-	userID, err := ValidateJWT(tokenString, tokenSecret)
+	return ValidateJWT(tokenString, keys)
+}
+
+func AuthorizeUser(ctx context.Context, headers http.Header, keys *KeySet, tokens PersonalTokenStore) (uuid.UUID, error) {
+	principal, err := Authorize(ctx, headers, keys, tokens)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
-	return userID, nil
+	return principal.UserID, nil
 }
 
 func GetAPIKey(headers http.Header) (string, error) {
@@ -11,7 +11,7 @@ import (
 
 func TestPasswordHashing(t *testing.T) {
 	password := "test"
-	hash, err := HashPassword(password)
+	hash, err := HashPassword(password, DefaultPasswordPolicy)
 	if err != nil {
 		t.Errorf("Hashing failed")
 		t.Fail()
@@ -31,26 +31,26 @@ func TestPasswordHashing(t *testing.T) {
 
 func TestJWTs(t *testing.T) {
 	userID := uuid.New()
-	tokenSecret := "i'm a secret token"
+	keys := NewKeySet(NewHMACKey("test", "i'm a secret token"))
 	expiresIn := time.Second * 2
 
-	signedString, err := MakeJWT(userID, tokenSecret, expiresIn)
+	signedString, err := MakeJWT(NewPrincipal(userID, RoleUser), keys, expiresIn)
 	if err != nil {
 		t.Fatalf("failed to make jwt: %v", err.Error())
 	}
 
-	retrievedUUID, err := ValidateJWT(signedString, tokenSecret)
+	principal, err := ValidateJWT(signedString, keys)
 	if err != nil {
 		t.Fatalf("failed to validate jwt: %v", err.Error())
 	}
 
-	if retrievedUUID != userID {
+	if principal.UserID != userID {
 		t.Fatal("uuid does not match")
 	}
 
 	time.Sleep(expiresIn)
 
-	_, err = ValidateJWT(signedString, tokenSecret)
+	_, err = ValidateJWT(signedString, keys)
 	if err == nil {
 		t.Fatalf("token should have expired by now")
 	}
@@ -79,3 +79,101 @@ func TestMakeRefreshToken(t *testing.T) {
 		t.Fatalf("The token is empty: %v", token)
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("failed to make refresh token: %v", err)
+	}
+
+	hash := HashRefreshToken(token, "key")
+	if hash == token {
+		t.Fatal("hash is the same as the token")
+	}
+	if hash != HashRefreshToken(token, "key") {
+		t.Fatal("hashing the same token twice gave different results")
+	}
+	if hash == HashRefreshToken(token, "other key") {
+		t.Fatal("hash does not depend on the key")
+	}
+
+	prefix := RefreshTokenPrefix(token)
+	if len(prefix) != 8 || prefix != token[:8] {
+		t.Fatalf("unexpected prefix: %v", prefix)
+	}
+}
+
+func TestCheckRefreshToken(t *testing.T) {
+	err := CheckRefreshToken(time.Now().Add(time.Hour), false)
+	if err != nil {
+		t.Fatalf("valid token was rejected: %v", err)
+	}
+
+	err = CheckRefreshToken(time.Now().Add(-time.Hour), false)
+	if err != ErrRefreshTokenExpired {
+		t.Fatalf("expected expired error, got: %v", err)
+	}
+
+	// a revoked token is reuse even if it has not expired yet
+	err = CheckRefreshToken(time.Now().Add(time.Hour), true)
+	if err != ErrRefreshTokenReused {
+		t.Fatalf("expected reuse error, got: %v", err)
+	}
+
+	err = CheckRefreshToken(time.Now().Add(-time.Hour), true)
+	if err != ErrRefreshTokenReused {
+		t.Fatalf("expected reuse error for expired revoked token, got: %v", err)
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	userID := uuid.New()
+	keys := NewKeySet(NewHMACKey("test", "i'm a secret token"))
+
+	challenge, err := MakeMFAChallenge(userID, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to make challenge: %v", err)
+	}
+
+	retrievedUUID, err := ValidateMFAChallenge(challenge, keys)
+	if err != nil {
+		t.Fatalf("failed to validate challenge: %v", err)
+	}
+	if retrievedUUID != userID {
+		t.Fatal("uuid does not match")
+	}
+
+	// the challenge must not work as an access token and the other way round
+	_, err = ValidateJWT(challenge, keys)
+	if err == nil {
+		t.Fatal("challenge was accepted as an access token")
+	}
+
+	accessToken, err := MakeJWT(NewPrincipal(userID, RoleUser), keys, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to make jwt: %v", err)
+	}
+	_, err = ValidateMFAChallenge(accessToken, keys)
+	if err == nil {
+		t.Fatal("access token was accepted as an mfa challenge")
+	}
+}
+
+func TestEmailToken(t *testing.T) {
+	token, err := MakeEmailToken()
+	if err != nil {
+		t.Fatalf("failed to make token: %v", err)
+	}
+	other, err := MakeEmailToken()
+	if err != nil {
+		t.Fatalf("failed to make token: %v", err)
+	}
+	if token == other {
+		t.Fatal("two tokens are the same")
+	}
+
+	hash := HashEmailToken(token)
+	if hash == token || hash != HashEmailToken(token) {
+		t.Fatal("hash is not a stable digest of the token")
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+const (
+	AccessTokenCookie  = "chirpy_access"
+	RefreshTokenCookie = "chirpy_refresh"
+	// the csrf cookie is readable from javascript, which copies it into
+	// the CSRF header on every request that changes something
+	CSRFCookie = "chirpy_csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+var ErrCSRFTokenMismatch = errors.New("missing or invalid csrf token")
+
+func MakeCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// UsesSessionCookie reports whether a request is authenticated by cookie
+// rather than an Authorization header.
+func UsesSessionCookie(headers http.Header) bool {
+	if headers.Get("Authorization") != "" {
+		return false
+	}
+	_, accessErr := getCookie(headers, AccessTokenCookie)
+	_, refreshErr := getCookie(headers, RefreshTokenCookie)
+	return accessErr == nil || refreshErr == nil
+}
+
+// CheckCSRF does the double submit check: the CSRF header has to match the
+// CSRF cookie. Another site can make the browser send the cookie but can't
+// read it to set the header.
+func CheckCSRF(headers http.Header) error {
+	cookie, err := getCookie(headers, CSRFCookie)
+	if err != nil {
+		return ErrCSRFTokenMismatch
+	}
+	header := headers.Get(CSRFHeader)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) != 1 {
+		return ErrCSRFTokenMismatch
+	}
+	return nil
+}
+
+func getCookie(headers http.Header, name string) (string, error) {
+	r := http.Request{Header: headers}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	if cookie.Value == "" {
+		return "", http.ErrNoCookie
+	}
+	return cookie.Value, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetBearerTokenFromCookie(t *testing.T) {
+	headers := http.Header{}
+	headers.Add("Cookie", AccessTokenCookie+"=access; "+RefreshTokenCookie+"=refresh")
+
+	token, err := GetBearerToken(headers)
+	if err != nil || token != "access" {
+		t.Fatalf("expected the access cookie, got %q %v", token, err)
+	}
+	token, err = GetRefreshToken(headers)
+	if err != nil || token != "refresh" {
+		t.Fatalf("expected the refresh cookie, got %q %v", token, err)
+	}
+	if !UsesSessionCookie(headers) {
+		t.Fatal("request should count as a cookie session")
+	}
+
+	// the header wins over the cookie
+	headers.Set("Authorization", "Bearer header")
+	token, err = GetBearerToken(headers)
+	if err != nil || token != "header" {
+		t.Fatalf("expected the header token, got %q %v", token, err)
+	}
+	if UsesSessionCookie(headers) {
+		t.Fatal("request with an Authorization header is not a cookie session")
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	csrf, err := MakeCSRFToken()
+	if err != nil {
+		t.Fatalf("failed to make csrf token: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Add("Cookie", CSRFCookie+"="+csrf)
+	if CheckCSRF(headers) == nil {
+		t.Fatal("missing header should fail")
+	}
+
+	headers.Set(CSRFHeader, "wrong")
+	if CheckCSRF(headers) == nil {
+		t.Fatal("wrong header should fail")
+	}
+
+	headers.Set(CSRFHeader, csrf)
+	if err := CheckCSRF(headers); err != nil {
+		t.Fatalf("matching token should pass: %v", err)
+	}
+}
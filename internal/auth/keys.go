@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is a single key that tokens can be signed and verified with.
+// The signing method is fixed per key, so a token is only ever verified
+// with the algorithm its key was created for.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(id, secret string) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+func NewRSAKey(id string, key *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodRS256,
+		signKey:   key,
+		verifyKey: &key.PublicKey,
+	}
+}
+
+func NewEd25519Key(id string, key ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodEdDSA,
+		signKey:   key,
+		verifyKey: key.Public(),
+	}
+}
+
+// ParsePrivateKeyPEM reads an RSA or Ed25519 private key in PKCS#8 form,
+// or an RSA key in PKCS#1 form.
+func ParsePrivateKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found for key %v", id)
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, key), nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, key), nil
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, key), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T for key %v", parsed, id)
+	}
+}
+
+// KeySet holds every key that tokens may currently be verified with. Only
+// the current key signs new tokens, older keys stay in the set until the
+// tokens they signed have expired.
+type KeySet struct {
+	keys    map[string]*SigningKey
+	current string
+}
+
+func NewKeySet(current *SigningKey, others ...*SigningKey) *KeySet {
+	keySet := &KeySet{
+		keys:    map[string]*SigningKey{current.ID: current},
+		current: current.ID,
+	}
+	for _, key := range others {
+		keySet.keys[key.ID] = key
+	}
+	return keySet
+}
+
+// LoadKeySet loads every .pem file in dir, using the file name without the
+// extension as the key ID. currentID selects the key that signs new tokens.
+func LoadKeySet(dir, currentID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var current *SigningKey
+	others := []*SigningKey{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParsePrivateKeyPEM(id, data)
+		if err != nil {
+			return nil, err
+		}
+		if id == currentID {
+			current = key
+		} else {
+			others = append(others, key)
+		}
+	}
+
+	if current == nil {
+		return nil, fmt.Errorf("signing key %v not found in %v", currentID, dir)
+	}
+	return NewKeySet(current, others...), nil
+}
+
+func (ks *KeySet) Current() *SigningKey {
+	return ks.keys[ks.current]
+}
+
+func (ks *KeySet) Lookup(id string) (*SigningKey, bool) {
+	key, ok := ks.keys[id]
+	return key, ok
+}
+
+// Algorithms returns the signing algorithms used by keys in the set.
+func (ks *KeySet) Algorithms() []string {
+	seen := map[string]struct{}{}
+	algs := []string{}
+	for _, key := range ks.keys {
+		alg := key.Method.Alg()
+		if _, ok := seen[alg]; ok {
+			continue
+		}
+		seen[alg] = struct{}{}
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	return algs
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every asymmetric key in the set. HMAC
+// keys are never published since the verifying key is the signing key.
+func (ks *KeySet) JWKS() JWKS {
+	ids := []string{}
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := ks.keys[id]
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func makeTestRSAKey(t *testing.T, id string) *SigningKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	return NewRSAKey(id, key)
+}
+
+func makeTestEd25519Key(t *testing.T, id string) *SigningKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	return NewEd25519Key(id, key)
+}
+
+func TestAsymmetricJWTs(t *testing.T) {
+	keySets := map[string]*KeySet{
+		"RS256": NewKeySet(makeTestRSAKey(t, "rsa")),
+		"EdDSA": NewKeySet(makeTestEd25519Key(t, "ed")),
+	}
+
+	for alg, keys := range keySets {
+		userID := uuid.New()
+		signedString, err := MakeJWT(NewPrincipal(userID, RoleUser), keys, time.Minute)
+		if err != nil {
+			t.Fatalf("%v: failed to make jwt: %v", alg, err)
+		}
+
+		token, _, err := jwt.NewParser().ParseUnverified(signedString, &jwt.RegisteredClaims{})
+		if err != nil {
+			t.Fatalf("%v: failed to parse jwt: %v", alg, err)
+		}
+		if token.Method.Alg() != alg {
+			t.Fatalf("expected alg %v, got %v", alg, token.Method.Alg())
+		}
+		if token.Header["kid"] != keys.Current().ID {
+			t.Fatalf("%v: kid header is %v", alg, token.Header["kid"])
+		}
+
+		principal, err := ValidateJWT(signedString, keys)
+		if err != nil {
+			t.Fatalf("%v: failed to validate jwt: %v", alg, err)
+		}
+		if principal.UserID != userID {
+			t.Fatalf("%v: uuid does not match", alg)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := makeTestEd25519Key(t, "old")
+	newKey := makeTestEd25519Key(t, "new")
+
+	oldToken, err := MakeJWT(NewPrincipal(uuid.New(), RoleUser), NewKeySet(oldKey), time.Minute)
+	if err != nil {
+		t.Fatalf("failed to make jwt: %v", err)
+	}
+
+	rotated := NewKeySet(newKey, oldKey)
+	_, err = ValidateJWT(oldToken, rotated)
+	if err != nil {
+		t.Fatalf("token from retired key should still validate: %v", err)
+	}
+
+	_, err = ValidateJWT(oldToken, NewKeySet(newKey))
+	if err == nil {
+		t.Fatal("token from a removed key should not validate")
+	}
+}
+
+func TestValidateJWTPinsAlgorithm(t *testing.T) {
+	rsaKey := makeTestRSAKey(t, "rsa")
+	keys := NewKeySet(rsaKey)
+
+	// sign an HS256 token using the public key as the hmac secret, the
+	// classic algorithm confusion attack
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.verifyKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	forged.Header["kid"] = rsaKey.ID
+	forgedString, err := forged.SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("failed to sign forged token: %v", err)
+	}
+
+	_, err = ValidateJWT(forgedString, keys)
+	if err == nil {
+		t.Fatal("token with a swapped algorithm should not validate")
+	}
+
+	// an unsigned token must not validate either
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Subject: uuid.New().String(),
+	})
+	unsigned.Header["kid"] = rsaKey.ID
+	unsignedString, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("failed to make unsigned token: %v", err)
+	}
+	_, err = ValidateJWT(unsignedString, keys)
+	if err == nil {
+		t.Fatal("unsigned token should not validate")
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	key, err := ParsePrivateKeyPEM("ed", data)
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	if key.Method.Alg() != "EdDSA" {
+		t.Fatalf("unexpected alg: %v", key.Method.Alg())
+	}
+
+	_, err = ParsePrivateKeyPEM("bad", []byte("not a key"))
+	if err == nil {
+		t.Fatal("parsing garbage should fail")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	keys := NewKeySet(
+		makeTestEd25519Key(t, "ed"),
+		makeTestRSAKey(t, "rsa"),
+		NewHMACKey("hmac", "secret"),
+	)
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %v", len(jwks.Keys))
+	}
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kid {
+		case "ed":
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.X == "" {
+				t.Fatalf("bad ed25519 jwk: %+v", jwk)
+			}
+		case "rsa":
+			if jwk.Kty != "RSA" || jwk.N == "" || jwk.E != "AQAB" {
+				t.Fatalf("bad rsa jwk: %+v", jwk)
+			}
+		default:
+			t.Fatalf("unexpected key in jwks: %v", jwk.Kid)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import "time"
+
+// LockoutPolicy decides how long logins are blocked after repeated
+// failures. Once Threshold failures have been seen the lock starts at Base
+// and doubles with every further failure, up to Max.
+type LockoutPolicy struct {
+	Threshold int32
+	Base      time.Duration
+	Max       time.Duration
+}
+
+var (
+	AccountLockout = LockoutPolicy{Threshold: 5, Base: 30 * time.Second, Max: time.Hour}
+	// addresses can be shared by many users behind the same NAT, so they
+	// get more room before anything is blocked
+	IPLockout = LockoutPolicy{Threshold: 20, Base: 30 * time.Second, Max: time.Hour}
+	// every password reset request counts, not just failed ones, since each
+	// one sends an email
+	PasswordResetLockout = LockoutPolicy{Threshold: 10, Base: time.Minute, Max: time.Hour}
+)
+
+func (policy LockoutPolicy) Duration(failures int32) time.Duration {
+	if failures < policy.Threshold {
+		return 0
+	}
+
+	duration := policy.Base
+	for i := policy.Threshold; i < failures; i++ {
+		duration *= 2
+		if duration >= policy.Max {
+			return policy.Max
+		}
+	}
+	return duration
+}
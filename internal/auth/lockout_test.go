@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, Base: time.Second, Max: time.Minute}
+
+	cases := map[int32]time.Duration{
+		0:   0,
+		2:   0,
+		3:   time.Second,
+		4:   2 * time.Second,
+		5:   4 * time.Second,
+		9:   time.Minute,
+		100: time.Minute,
+	}
+	for failures, expected := range cases {
+		duration := policy.Duration(failures)
+		if duration != expected {
+			t.Fatalf("%v failures: expected %v, got %v", failures, expected, duration)
+		}
+	}
+}
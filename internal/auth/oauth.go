@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// PKCE verifiers are 43 to 128 characters, RFC 7636 section 4.1
+const (
+	pkceVerifierMinLength = 43
+	pkceVerifierMaxLength = 128
+)
+
+// MakeOAuthToken returns a random value for OAuth2 client secrets,
+// authorization codes and refresh tokens. Only its hash, from
+// HashOAuthToken, should be stored.
+func MakeOAuthToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashOAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PKCEChallenge returns the S256 code challenge for a verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE checks a code verifier against the S256 challenge sent with
+// the authorization request. The plain method is not supported.
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < pkceVerifierMinLength || len(verifier) > pkceVerifierMaxLength {
+		return false
+	}
+	expected := PKCEChallenge(verifier)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// CheckOAuthSecret compares a presented client secret with the stored hash.
+func CheckOAuthSecret(secret, secretHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashOAuthToken(secret)), []byte(secretHash)) == 1
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// the example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if PKCEChallenge(verifier) != challenge {
+		t.Fatalf("unexpected challenge %v", PKCEChallenge(verifier))
+	}
+	if !VerifyPKCE(verifier, challenge) {
+		t.Fatal("valid verifier was rejected")
+	}
+	if VerifyPKCE(verifier[:len(verifier)-1]+"x", challenge) {
+		t.Fatal("wrong verifier was accepted")
+	}
+	if VerifyPKCE("short", PKCEChallenge("short")) {
+		t.Fatal("verifier below the minimum length was accepted")
+	}
+}
+
+func TestCheckOAuthSecret(t *testing.T) {
+	secret, err := MakeOAuthToken()
+	if err != nil {
+		t.Fatalf("failed to make secret: %v", err)
+	}
+	hash := HashOAuthToken(secret)
+	if !CheckOAuthSecret(secret, hash) {
+		t.Fatal("secret does not match its hash")
+	}
+	if CheckOAuthSecret("wrong", hash) {
+		t.Fatal("wrong secret matched")
+	}
+}
+
+func TestJWTCarriesClientID(t *testing.T) {
+	keys := NewKeySet(NewHMACKey("test", "secret"))
+	principal := NewScopedPrincipal(uuid.New(), RoleUser, ScopeChirpsWrite)
+	principal.ClientID = uuid.NewString()
+
+	signedString, err := MakeJWT(principal, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to make jwt: %v", err)
+	}
+	retrieved, err := ValidateJWT(signedString, keys)
+	if err != nil {
+		t.Fatalf("failed to validate jwt: %v", err)
+	}
+	if retrieved.ClientID != principal.ClientID {
+		t.Fatalf("client id was %v", retrieved.ClientID)
+	}
+	if !DelegableScope(ScopeChirpsWrite) || DelegableScope(ScopeUserWrite) {
+		t.Fatal("delegable scopes are wrong")
+	}
+}
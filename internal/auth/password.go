@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrPasswordNotSet      = errors.New("password has not been set for this account")
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownPasswordHash = errors.New("unrecognized password hash format")
+)
+
+// PasswordPolicy decides how new passwords are hashed. Hashes made under
+// an older, weaker policy still verify and can be upgraded with NeedsRehash.
+type PasswordPolicy struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // in KiB
+	Argon2Threads uint8
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	Algorithm:     AlgorithmArgon2id,
+	BcryptCost:    12,
+	Argon2Time:    1,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// stored hashes are checked with the memory they ask for, so it is
+	// capped to keep a bad row from allocating without bound
+	argon2MaxMemory = 1024 * 1024 // 1 GiB in KiB
+)
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func (policy PasswordPolicy) Validate() error {
+	switch policy.Algorithm {
+	case AlgorithmBcrypt:
+		if policy.BcryptCost < bcrypt.MinCost || policy.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if policy.Argon2Time < 1 || policy.Argon2Memory < 8*uint32(policy.Argon2Threads) || policy.Argon2Memory > argon2MaxMemory || policy.Argon2Threads < 1 {
+			return errors.New("invalid argon2id parameters")
+		}
+	default:
+		return fmt.Errorf("unsupported password hash algorithm %v", policy.Algorithm)
+	}
+	return nil
+}
+
+func HashPassword(password string, policy PasswordPolicy) (string, error) {
+	switch policy.Algorithm {
+	case AlgorithmBcrypt:
+		hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), policy.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashedPass), nil
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, policy.Argon2Time, policy.Argon2Memory, policy.Argon2Threads, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			policy.Argon2Memory,
+			policy.Argon2Time,
+			policy.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm %v", policy.Algorithm)
+	}
+}
+
+// CheckPasswordHash works out the algorithm and parameters from the stored
+// hash itself, so it does not depend on the current policy.
+func CheckPasswordHash(password, hash string) error {
+	switch passwordHashAlgorithm(hash) {
+	case AlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	case AlgorithmArgon2id:
+		params, err := parseArgon2Hash(hash)
+		if err != nil {
+			return err
+		}
+		key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+		if subtle.ConstantTimeCompare(key, params.key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case "unset":
+		// accounts created before passwords existed (migration 003) can
+		// never log in with a password
+		return ErrPasswordNotSet
+	default:
+		return ErrUnknownPasswordHash
+	}
+}
+
+// NeedsRehash reports whether a stored hash is weaker than the policy, or
+// uses a different algorithm, and should be replaced on the next login.
+func (policy PasswordPolicy) NeedsRehash(hash string) bool {
+	algorithm := passwordHashAlgorithm(hash)
+	if algorithm != policy.Algorithm {
+		return true
+	}
+
+	switch algorithm {
+	case AlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true
+		}
+		return cost < policy.BcryptCost
+	case AlgorithmArgon2id:
+		params, err := parseArgon2Hash(hash)
+		if err != nil {
+			return true
+		}
+		return params.time < policy.Argon2Time ||
+			params.memory < policy.Argon2Memory ||
+			params.threads < policy.Argon2Threads
+	}
+	return true
+}
+
+func passwordHashAlgorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return AlgorithmBcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return AlgorithmArgon2id
+	case hash == "unset":
+		return "unset"
+	}
+	return ""
+}
+
+func parseArgon2Hash(hash string) (argon2Params, error) {
+	// $argon2id$v=19$m=65536,t=1,p=4$salt$key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, ErrUnknownPasswordHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return argon2Params{}, ErrUnknownPasswordHash
+	}
+
+	params := argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads)
+	if err != nil {
+		return argon2Params{}, ErrUnknownPasswordHash
+	}
+	// argon2.IDKey panics without at least one thread
+	if params.time < 1 || params.threads < 1 || params.memory > argon2MaxMemory {
+		return argon2Params{}, ErrUnknownPasswordHash
+	}
+
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, ErrUnknownPasswordHash
+	}
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(params.key) == 0 {
+		return argon2Params{}, ErrUnknownPasswordHash
+	}
+	return params, nil
+}
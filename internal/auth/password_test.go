@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Policy = PasswordPolicy{
+	Algorithm:     AlgorithmArgon2id,
+	Argon2Time:    1,
+	Argon2Memory:  1024,
+	Argon2Threads: 1,
+}
+
+var testBcryptPolicy = PasswordPolicy{
+	Algorithm:  AlgorithmBcrypt,
+	BcryptCost: bcrypt.MinCost + 1,
+}
+
+func TestHashPasswordAlgorithms(t *testing.T) {
+	for _, policy := range []PasswordPolicy{testArgon2Policy, testBcryptPolicy} {
+		hash, err := HashPassword("hunter2", policy)
+		if err != nil {
+			t.Fatalf("%v: hashing failed: %v", policy.Algorithm, err)
+		}
+		err = CheckPasswordHash("hunter2", hash)
+		if err != nil {
+			t.Fatalf("%v: compare failed: %v", policy.Algorithm, err)
+		}
+		err = CheckPasswordHash("hunter3", hash)
+		if err != ErrPasswordMismatch {
+			t.Fatalf("%v: expected mismatch, got: %v", policy.Algorithm, err)
+		}
+		if policy.NeedsRehash(hash) {
+			t.Fatalf("%v: fresh hash should not need a rehash", policy.Algorithm)
+		}
+	}
+
+	hash, err := HashPassword("hunter2", testArgon2Policy)
+	if err != nil {
+		t.Fatalf("hashing failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected argon2id hash format: %v", hash)
+	}
+}
+
+func TestCheckPasswordHashLegacy(t *testing.T) {
+	// the old HashPassword passed cost 1, which bcrypt bumps to its default
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter2"), 1)
+	if err != nil {
+		t.Fatalf("hashing failed: %v", err)
+	}
+	err = CheckPasswordHash("hunter2", string(legacy))
+	if err != nil {
+		t.Fatalf("legacy bcrypt hash should still verify: %v", err)
+	}
+
+	err = CheckPasswordHash("unset", "unset")
+	if err != ErrPasswordNotSet {
+		t.Fatalf("expected unset password to be rejected, got: %v", err)
+	}
+
+	err = CheckPasswordHash("anything", "plaintext")
+	if err != ErrUnknownPasswordHash {
+		t.Fatalf("expected unknown hash error, got: %v", err)
+	}
+
+	err = CheckPasswordHash("anything", "$argon2id$v=19$m=1024$broken")
+	if err != ErrUnknownPasswordHash {
+		t.Fatalf("expected malformed argon2id hash to be rejected, got: %v", err)
+	}
+
+	for _, params := range []string{"m=1024,t=0,p=1", "m=1024,t=1,p=0", "m=4194304,t=1,p=1"} {
+		hash := "$argon2id$v=19$" + params + "$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+		err = CheckPasswordHash("anything", hash)
+		if err != ErrUnknownPasswordHash {
+			t.Fatalf("expected argon2id hash with %v to be rejected, got: %v", params, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakBcrypt, err := HashPassword("hunter2", testBcryptPolicy)
+	if err != nil {
+		t.Fatalf("hashing failed: %v", err)
+	}
+
+	strongerBcrypt := testBcryptPolicy
+	strongerBcrypt.BcryptCost++
+	if !strongerBcrypt.NeedsRehash(weakBcrypt) {
+		t.Fatal("bcrypt hash below the policy cost should need a rehash")
+	}
+	if !testArgon2Policy.NeedsRehash(weakBcrypt) {
+		t.Fatal("bcrypt hash should need a rehash under an argon2id policy")
+	}
+
+	weakArgon2, err := HashPassword("hunter2", testArgon2Policy)
+	if err != nil {
+		t.Fatalf("hashing failed: %v", err)
+	}
+	strongerArgon2 := testArgon2Policy
+	strongerArgon2.Argon2Memory *= 2
+	if !strongerArgon2.NeedsRehash(weakArgon2) {
+		t.Fatal("argon2id hash with less memory should need a rehash")
+	}
+
+	if !testArgon2Policy.NeedsRehash("unset") {
+		t.Fatal("unset password should not be reported as up to date")
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	if err := DefaultPasswordPolicy.Validate(); err != nil {
+		t.Fatalf("default policy is invalid: %v", err)
+	}
+
+	policy := testBcryptPolicy
+	policy.BcryptCost = 1
+	if policy.Validate() == nil {
+		t.Fatal("bcrypt cost 1 should be rejected")
+	}
+
+	policy = testArgon2Policy
+	policy.Algorithm = "md5"
+	if policy.Validate() == nil {
+		t.Fatal("unknown algorithm should be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// personal access tokens carry a fixed prefix so they can be told apart
+// from JWTs, and spotted by secret scanners if they leak
+const (
+	personalTokenPrefix        = "chirpy_pat_"
+	personalTokenDisplayLength = len(personalTokenPrefix) + 6
+)
+
+var ErrPersonalTokenInvalid = errors.New("personal access token is invalid, expired or revoked")
+
+// PersonalTokenStore finds the owner of a personal access token. It gets
+// the token's hash, and should return ErrPersonalTokenInvalid for tokens
+// that are unknown, expired or revoked.
+type PersonalTokenStore interface {
+	LookupPersonalToken(ctx context.Context, tokenHash string) (Principal, error)
+}
+
+func MakePersonalToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return personalTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, personalTokenPrefix)
+}
+
+// HashPersonalToken returns what is stored in place of the token. The token
+// is random enough that a plain hash is safe.
+func HashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PersonalTokenDisplay returns the start of a token, enough for a user to
+// recognise it in a list without being able to use it.
+func PersonalTokenDisplay(token string) string {
+	if len(token) < personalTokenDisplayLength {
+		return token
+	}
+	return token[:personalTokenDisplayLength]
+}
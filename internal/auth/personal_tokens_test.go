@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type testTokenStore map[string]Principal
+
+func (s testTokenStore) LookupPersonalToken(ctx context.Context, tokenHash string) (Principal, error) {
+	principal, ok := s[tokenHash]
+	if !ok {
+		return Principal{}, ErrPersonalTokenInvalid
+	}
+	return principal, nil
+}
+
+func TestAuthorizePersonalToken(t *testing.T) {
+	keys := NewKeySet(NewHMACKey("test", "secret"))
+	token, err := MakePersonalToken()
+	if err != nil {
+		t.Fatalf("failed to make token: %v", err)
+	}
+	if !IsPersonalToken(token) {
+		t.Fatal("token is missing its prefix")
+	}
+
+	userID := uuid.New()
+	store := testTokenStore{
+		HashPersonalToken(token): NewScopedPrincipal(userID, RoleUser, ScopeChirpsWrite),
+	}
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer "+token)
+	principal, err := Authorize(context.Background(), headers, keys, store)
+	if err != nil {
+		t.Fatalf("failed to authorize: %v", err)
+	}
+	if principal.UserID != userID {
+		t.Fatal("uuid does not match")
+	}
+	if !principal.HasScopes(ScopeChirpsWrite) || principal.HasScopes(ScopeUserWrite) {
+		t.Fatalf("unexpected scopes %v", principal.Scopes)
+	}
+
+	other, err := MakePersonalToken()
+	if err != nil {
+		t.Fatalf("failed to make token: %v", err)
+	}
+	headers.Set("Authorization", "Bearer "+other)
+	_, err = Authorize(context.Background(), headers, keys, store)
+	if err == nil {
+		t.Fatal("unknown token should not authorize")
+	}
+	_, err = Authorize(context.Background(), headers, keys, nil)
+	if err == nil {
+		t.Fatal("personal token should not authorize without a store")
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	ScopeChirpsWrite    = "chirps:write"
+	ScopeChirpsModerate = "chirps:moderate"
+	ScopeUserRead       = "user:read"
+	ScopeUserWrite      = "user:write"
+	ScopeAdmin          = "admin"
+)
+
+var roleScopes = map[string][]string{
+	RoleUser:      {ScopeChirpsWrite, ScopeUserRead, ScopeUserWrite},
+	RoleModerator: {ScopeChirpsWrite, ScopeChirpsModerate, ScopeUserRead, ScopeUserWrite},
+	RoleAdmin:     {ScopeChirpsWrite, ScopeChirpsModerate, ScopeUserRead, ScopeUserWrite, ScopeAdmin},
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(roleScopes[RoleAdmin], scope)
+}
+
+// third party apps can't be granted scopes that would let them take over
+// the account or act as a moderator
+var delegableScopes = []string{ScopeChirpsWrite, ScopeUserRead}
+
+// DelegableScope reports whether an OAuth2 client may ask for the scope.
+func DelegableScope(scope string) bool {
+	return slices.Contains(delegableScopes, scope)
+}
+
+// ScopesForRole returns every scope a user with the role may be granted.
+func ScopesForRole(role string) []string {
+	return slices.Clone(roleScopes[role])
+}
+
+// Principal is who a request is made for and what it may do.
+type Principal struct {
+	UserID uuid.UUID
+	Role   string
+	Scopes []string
+	// the OAuth2 client acting for the user, empty for first party tokens
+	ClientID string
+}
+
+func NewPrincipal(userID uuid.UUID, role string) Principal {
+	return Principal{
+		UserID: userID,
+		Role:   role,
+		Scopes: ScopesForRole(role),
+	}
+}
+
+func (p Principal) HasRole(roles ...string) bool {
+	return slices.Contains(roles, p.Role)
+}
+
+func (p Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// JoinScopes writes scopes as a single space separated string, the same way
+// OAuth2 does. It is how they travel in a JWT and how they are stored.
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func SplitScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// NewScopedPrincipal builds the principal for a token that was granted
+// scope. It never gets more than the user's current role allows, so scopes
+// granted before a demotion stop working.
+func NewScopedPrincipal(userID uuid.UUID, role string, scope string) Principal {
+	allowed := ScopesForRole(role)
+	scopes := []string{}
+	for _, s := range SplitScopes(scope) {
+		if slices.Contains(allowed, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return Principal{
+		UserID: userID,
+		Role:   role,
+		Scopes: scopes,
+	}
+}
+
+var ErrNoPrincipal = errors.New("request has not been authorized")
+
+type principalKey struct{}
+
+// WithPrincipal stores the principal a request was authorized as, so the
+// handler behind the middleware doesn't have to check the token again.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, error) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	if !ok {
+		return Principal{}, ErrNoPrincipal
+	}
+	return principal, nil
+}
+
+func UserFromContext(ctx context.Context) (uuid.UUID, error) {
+	principal, err := PrincipalFromContext(ctx)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return principal.UserID, nil
+}
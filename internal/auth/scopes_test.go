@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPrincipalScopes(t *testing.T) {
+	user := NewPrincipal(uuid.New(), RoleUser)
+	if !user.HasScopes(ScopeChirpsWrite, ScopeUserWrite) {
+		t.Fatal("user is missing default scopes")
+	}
+	if user.HasScopes(ScopeAdmin) || user.HasRole(RoleAdmin) {
+		t.Fatal("user should not have admin access")
+	}
+
+	admin := NewPrincipal(uuid.New(), RoleAdmin)
+	if !admin.HasScopes(ScopeAdmin, ScopeChirpsModerate) {
+		t.Fatal("admin is missing scopes")
+	}
+	if !admin.HasRole(RoleModerator, RoleAdmin) {
+		t.Fatal("admin should match a list containing admin")
+	}
+
+	if ValidRole("superuser") || !ValidRole(RoleModerator) {
+		t.Fatal("role validation is wrong")
+	}
+}
+
+func TestJWTCarriesRoleAndScopes(t *testing.T) {
+	keys := NewKeySet(NewHMACKey("test", "secret"))
+	principal := Principal{
+		UserID: uuid.New(),
+		Role:   RoleModerator,
+		Scopes: []string{ScopeChirpsWrite},
+	}
+
+	signedString, err := MakeJWT(principal, keys, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to make jwt: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer "+signedString)
+	retrieved, err := Authorize(context.Background(), headers, keys, nil)
+	if err != nil {
+		t.Fatalf("failed to authorize: %v", err)
+	}
+	if retrieved.UserID != principal.UserID || retrieved.Role != RoleModerator {
+		t.Fatalf("principal does not match: %+v", retrieved)
+	}
+	// a token only carries the scopes it was issued with, not every scope
+	// the role could have
+	if !retrieved.HasScopes(ScopeChirpsWrite) || retrieved.HasScopes(ScopeChirpsModerate) {
+		t.Fatalf("unexpected scopes: %v", retrieved.Scopes)
+	}
+}
+
+func TestScopedPrincipalLimitedByRole(t *testing.T) {
+	principal := NewScopedPrincipal(uuid.New(), RoleUser, "chirps:write chirps:moderate admin")
+	if !principal.HasScopes(ScopeChirpsWrite) {
+		t.Fatal("allowed scope was dropped")
+	}
+	if principal.HasScopes(ScopeChirpsModerate) || principal.HasScopes(ScopeAdmin) {
+		t.Fatalf("scopes beyond the role were kept: %v", principal.Scopes)
+	}
+}
+
+func TestPrincipalContext(t *testing.T) {
+	if _, err := PrincipalFromContext(context.Background()); err != ErrNoPrincipal {
+		t.Fatalf("expected ErrNoPrincipal without a principal, got %v", err)
+	}
+
+	principal := NewPrincipal(uuid.New(), RoleModerator)
+	ctx := WithPrincipal(context.Background(), principal)
+	got, err := PrincipalFromContext(ctx)
+	if err != nil {
+		t.Fatalf("failed to get principal: %v", err)
+	}
+	if got.UserID != principal.UserID || !got.HasScopes(ScopeChirpsModerate) {
+		t.Fatalf("got a different principal back: %+v", got)
+	}
+	userID, err := UserFromContext(ctx)
+	if err != nil || userID != principal.UserID {
+		t.Fatalf("expected user %v, got %v (%v)", principal.UserID, userID, err)
+	}
+}
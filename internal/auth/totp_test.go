@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret from the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range cases {
+		code, err := GenerateTOTPCode(rfcTOTPSecret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("failed to generate code: %v", err)
+		}
+		if code != expected {
+			t.Fatalf("at %v expected %v, got %v", unix, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+
+	now := time.Now()
+	code, err := GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("failed to generate code: %v", err)
+	}
+
+	if !ValidateTOTPCode(code, secret, now) {
+		t.Fatal("current code was rejected")
+	}
+	if !ValidateTOTPCode(code, secret, now.Add(totpPeriod)) {
+		t.Fatal("code from the previous step should be accepted")
+	}
+	if ValidateTOTPCode(code, secret, now.Add(totpPeriod*3)) {
+		t.Fatal("code from three steps ago should be rejected")
+	}
+	if ValidateTOTPCode("", secret, now) || ValidateTOTPCode("12345", secret, now) {
+		t.Fatal("malformed codes should be rejected")
+	}
+}
+
+func TestMatchTOTPCodeStep(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, err := GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("failed to generate code: %v", err)
+	}
+
+	want := now.Unix() / int64(totpPeriod.Seconds())
+	step, ok := MatchTOTPCode(code, secret, now)
+	if !ok || step != want {
+		t.Fatalf("expected step %v, got %v (ok %v)", want, step, ok)
+	}
+	// accepted a step later for clock drift, but still the step it was made for
+	step, ok = MatchTOTPCode(code, secret, now.Add(totpPeriod))
+	if !ok || step != want {
+		t.Fatalf("expected step %v a period later, got %v (ok %v)", want, step, ok)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("ABC", "Chirpy", "user@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Fatalf("unexpected uri: %v", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Chirpy") {
+		t.Fatalf("uri is missing parameters: %v", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("failed to generate codes: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("expected 10 codes, got %v", len(codes))
+	}
+
+	seen := map[string]struct{}{}
+	for _, code := range codes {
+		if _, ok := seen[code]; ok {
+			t.Fatalf("duplicate code %v", code)
+		}
+		seen[code] = struct{}{}
+	}
+
+	code := codes[0]
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+	if HashRecoveryCode(code) != HashRecoveryCode(typed) {
+		t.Fatal("recovery code hash should ignore case and dashes")
+	}
+}
@@ -0,0 +1,101 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// keys are flat names like abc.jpg, so they can't climb out of the store
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// BlobStore keeps uploaded files. The server only talks to this interface,
+// so the local directory can be swapped for an S3 compatible bucket.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data io.Reader) error
+	Delete(ctx context.Context, key string) error
+	// URL is where clients can fetch the blob from.
+	URL(key string) string
+}
+
+// LocalStore keeps blobs as files in a directory. Handler serves them at
+// BaseURL.
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStore(dir, baseURL string) (*LocalStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key, contentType string, data io.Reader) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+
+	// written to a temporary file first so a half written blob is never
+	// served
+	file, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(file.Name(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(s.dir, key))
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + url.PathEscape(key)
+}
+
+// Handler serves blobs by key. Mount it with the path prefix of BaseURL
+// stripped. There are no directory listings, so a blob can only be found
+// by someone who was given its url.
+func (s *LocalStore) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !keyPattern.MatchString(strings.TrimPrefix(r.URL.Path, "/")) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// keys are never reused, so a blob never changes
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		files.ServeHTTP(w, r)
+	})
+}
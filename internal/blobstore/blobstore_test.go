@@ -0,0 +1,70 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(dir, "media"), "http://localhost:8080/media/")
+	if err != nil {
+		t.Fatalf("failed to make store: %v", err)
+	}
+	ctx := context.Background()
+
+	err = store.Put(ctx, "abc.png", "image/png", strings.NewReader("png data"))
+	if err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	if url := store.URL("abc.png"); url != "http://localhost:8080/media/abc.png" {
+		t.Fatalf("unexpected url %v", url)
+	}
+
+	resp := httptest.NewRecorder()
+	store.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/abc.png", nil))
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Code != http.StatusOK || string(body) != "png data" {
+		t.Fatalf("unexpected response %v %q", resp.Code, body)
+	}
+	if resp.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("blobs should be served with nosniff")
+	}
+
+	err = store.Delete(ctx, "abc.png")
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "media", "abc.png")); !os.IsNotExist(err) {
+		t.Fatal("blob should be gone")
+	}
+	if err := store.Delete(ctx, "abc.png"); err != nil {
+		t.Fatalf("deleting a missing blob should not fail: %v", err)
+	}
+}
+
+func TestLocalStoreRejectsBadKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatalf("failed to make store: %v", err)
+	}
+	for _, key := range []string{"", "../escape", "a/b", ".hidden"} {
+		if err := store.Put(context.Background(), key, "text/plain", strings.NewReader("x")); err != ErrInvalidKey {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	for _, path := range []string{"/", "/.upload-123"} {
+		resp := httptest.NewRecorder()
+		store.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("GET %v = %v, want 404", path, resp.Code)
+		}
+	}
+}
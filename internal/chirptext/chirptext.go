@@ -0,0 +1,61 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// a tag or mention has to start a word, so emails and urls with # in them
+// aren't picked up. \w and \b only know ascii, so letters and digits from
+// any script are spelled out, and the word runs as far as it goes before
+// its length is checked
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#@/])#([\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#@/])@([\p{L}\p{N}_]+)`)
+	handlePattern  = regexp.MustCompile(`^\w{3,30}$`)
+)
+
+// Hashtags returns the tags in a chirp body, lowercased, without the # and
+// without repeats.
+func Hashtags(body string) []string {
+	return extract(hashtagPattern, body, 1, 50)
+}
+
+// Mentions returns the handles mentioned in a chirp body, lowercased,
+// without the @ and without repeats. They may not belong to anyone.
+func Mentions(body string) []string {
+	return extract(mentionPattern, body, 3, 30)
+}
+
+// ValidHandle reports whether a handle can be mentioned: 3 to 30 letters,
+// digits or underscores.
+func ValidHandle(handle string) bool {
+	return handlePattern.MatchString(handle)
+}
+
+// NormalizeTag turns a tag from a url into the form it is stored in.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// extract finds the words pattern captures that are between minLength and
+// maxLength characters long. A word that is too long is skipped rather
+// than cut short.
+func extract(pattern *regexp.Regexp, body string, minLength, maxLength int) []string {
+	found := []string{}
+	seen := map[string]bool{}
+	for _, match := range pattern.FindAllStringSubmatch(body, -1) {
+		length := utf8.RuneCountInString(match[1])
+		if length < minLength || length > maxLength {
+			continue
+		}
+		word := strings.ToLower(match[1])
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		found = append(found, word)
+	}
+	return found
+}
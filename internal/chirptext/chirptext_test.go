@@ -0,0 +1,60 @@
+package chirptext
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", []string{}},
+		{"#Go is fun #go #golang", []string{"go", "golang"}},
+		{"(#first), #second!", []string{"first", "second"}},
+		{"see example.com/#anchor and a##b", []string{}},
+		{"#", []string{}},
+		{"#Café au lait, #日本 and café#not", []string{"café", "日本"}},
+		{"#" + strings.Repeat("a", 51), []string{}},
+	}
+	for _, c := range cases {
+		got := Hashtags(c.body)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("Hashtags(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"hi @Alice and @bob_99, @alice again", []string{"alice", "bob_99"}},
+		{"mail me at jo@example.com", []string{}},
+		{"@ab is too short", []string{}},
+		{"@carol's idea", []string{"carol"}},
+		{"@José said hi", []string{"josé"}},
+	}
+	for _, c := range cases {
+		got := Mentions(c.body)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("Mentions(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	for _, handle := range []string{"bob", "Alice_1", "abcdefghijklmnopqrstuvwxyz1234"} {
+		if !ValidHandle(handle) {
+			t.Errorf("%q should be valid", handle)
+		}
+	}
+	for _, handle := range []string{"", "ab", "has space", "dash-ed", "abcdefghijklmnopqrstuvwxyz12345"} {
+		if ValidHandle(handle) {
+			t.Errorf("%q should not be valid", handle)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package chirptext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchQuery turns what a user typed into a search box into postgres
+// to_tsquery syntax. Every term has to match. "Quoted words" match as a
+// phrase and a trailing * matches any word starting with the term, so
+// chirp* finds chirpy. Anything that isn't a letter or digit is dropped,
+// so the result is always safe to hand to to_tsquery. It is empty if there
+// was nothing to search for.
+func SearchQuery(q string) string {
+	terms := []string{}
+	for i, part := range strings.Split(q, `"`) {
+		// the split alternates between plain text and quoted phrases
+		if i%2 == 1 {
+			if phrase := phraseQuery(part, false); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			if phrase := phraseQuery(word, prefix); phrase != "" {
+				terms = append(terms, phrase)
+			}
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// phraseQuery matches the words of text next to each other, the last one
+// as a prefix if asked.
+func phraseQuery(text string, prefix bool) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
@@ -0,0 +1,26 @@
+package chirptext
+
+import "testing"
+
+func TestSearchQuery(t *testing.T) {
+	cases := []struct {
+		q    string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"Hello world", "hello & world"},
+		{`"good morning" chirpy`, "(good <-> morning) & chirpy"},
+		{"chirp*", "chirp:*"},
+		{"don't", "(don <-> t)"},
+		{`it's "unclosed phrase`, "(it <-> s) & (unclosed <-> phrase)"},
+		{"a:* | !b & (c)", "a:* & b & c"},
+		{"*** & ||", ""},
+	}
+	for _, c := range cases {
+		got := SearchQuery(c.q)
+		if got != c.want {
+			t.Errorf("SearchQuery(%q) = %q, want %q", c.q, got, c.want)
+		}
+	}
+}
@@ -7,23 +7,39 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const addChirp = `-- name: AddChirp :one
-insert into chirps (id, created_at, updated_at, body, user_id)
-values (gen_random_uuid(), now(), now(), $1, $2)
-returning id, created_at, updated_at, body, user_id
+insert into chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, reshare_of, reshare_kind)
+select new_id, now(), now(), $1, $2, $3,
+    coalesce((select parent.thread_id from chirps parent where parent.id = $3), new_id),
+    $4, $5
+from (select gen_random_uuid() as new_id) as ids
+returning id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by
 `
 
 type AddChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	InReplyTo   uuid.NullUUID
+	ReshareOf   uuid.NullUUID
+	ReshareKind sql.NullString
 }
 
+// A reply joins the thread of the chirp it replies to, anything else starts
+// a thread of its own.
 func (q *Queries) AddChirp(ctx context.Context, arg AddChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, addChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, addChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.ReshareOf,
+		arg.ReshareKind,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -31,10 +47,32 @@ func (q *Queries) AddChirp(ctx context.Context, arg AddChirpParams) (Chirp, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.TombstonedAt,
+		&i.LikeCount,
+		&i.ReshareOf,
+		&i.ReshareKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
 	)
 	return i, err
 }
 
+const chirpHasReplies = `-- name: ChirpHasReplies :one
+select exists(select 1 from chirps where in_reply_to = $1)
+`
+
+func (q *Queries) ChirpHasReplies(ctx context.Context, inReplyTo uuid.NullUUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpHasReplies, inReplyTo)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const deleteChirp = `-- name: DeleteChirp :exec
 delete from chirps
 where id = $1
@@ -45,13 +83,194 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-select id, created_at, updated_at, body, user_id from chirps
-order by created_at asc
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+delete from chirps
+where user_id = $1 and reshare_of = $2 and reshare_kind = 'rechirp'
+`
+
+type DeleteRechirpParams struct {
+	UserID    uuid.UUID
+	ReshareOf uuid.NullUUID
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.ReshareOf)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const editChirp = `-- name: EditChirp :one
+update chirps
+set body = $2, updated_at = now(), edited_at = now()
+where id = $1
+returning id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by
+`
+
+type EditChirpParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) EditChirp(ctx context.Context, arg EditChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editChirp, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.TombstonedAt,
+		&i.LikeCount,
+		&i.ReshareOf,
+		&i.ReshareKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+	)
+	return i, err
+}
+
+const getChirpById = `-- name: GetChirpById :one
+select id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by from chirps
+where id = $1
+`
+
+func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpById, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.TombstonedAt,
+		&i.LikeCount,
+		&i.ReshareOf,
+		&i.ReshareKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+	)
+	return i, err
+}
+
+const getChirpByIdForUpdate = `-- name: GetChirpByIdForUpdate :one
+select id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by from chirps
+where id = $1
+for update
+`
+
+func (q *Queries) GetChirpByIdForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIdForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.EditedAt,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.TombstonedAt,
+		&i.LikeCount,
+		&i.ReshareOf,
+		&i.ReshareKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+	)
+	return i, err
+}
+
+const getChirpThread = `-- name: GetChirpThread :many
+with recursive thread as (
+    select chirps.id, 0 as depth,
+        array[to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text] as sort_path
+    from chirps
+    where chirps.thread_id = (select c.thread_id from chirps c where c.id = $1)
+        and chirps.in_reply_to is null
+    union all
+    select reply.id, thread.depth + 1,
+        thread.sort_path || (to_char(reply.created_at, 'YYYYMMDDHH24MISSUS') || reply.id::text)
+    from chirps reply
+    join thread on reply.in_reply_to = thread.id
+)
+select chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.edited_at, chirps.in_reply_to, chirps.thread_id, chirps.tombstoned_at, chirps.like_count, chirps.reshare_of, chirps.reshare_kind, chirps.rechirp_count, chirps.quote_count, chirps.deleted_at, chirps.deleted_by, thread.depth
+from thread
+join chirps on chirps.id = thread.id
+order by thread.sort_path
+`
+
+type GetChirpThreadRow struct {
+	Chirp Chirp
+	Depth int32
+}
+
+// Every chirp in the thread of the given chirp, depth first with replies
+// oldest first. Chirps whose parent is gone start their own branch at
+// depth 0.
+func (q *Queries) GetChirpThread(ctx context.Context, id uuid.UUID) ([]GetChirpThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpThread, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpThreadRow
+	for rows.Next() {
+		var i GetChirpThreadRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.EditedAt,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ThreadID,
+			&i.Chirp.TombstonedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.ReshareOf,
+			&i.Chirp.ReshareKind,
+			&i.Chirp.RechirpCount,
+			&i.Chirp.QuoteCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.DeletedBy,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIds = `-- name: GetChirpsByIds :many
+select id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by from chirps
+where id = any($1::uuid[])
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetChirpsByIds(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIds, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +284,17 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.TombstonedAt,
+			&i.LikeCount,
+			&i.ReshareOf,
+			&i.ReshareKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -79,14 +309,36 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getAllChirpsByAuthor = `-- name: GetAllChirpsByAuthor :many
-select id, created_at, updated_at, body, user_id from chirps
-where user_id = $1
-order by created_at asc
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+select id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by from chirps
+where deleted_at is null
+    and not (reshare_kind = 'rechirp' and reshare_of is null)
+    and ($1::uuid is null or user_id = $1)
+    and ($1::uuid is null
+        or not exists (select 1 from pinned_chirps where pinned_chirps.chirp_id = chirps.id))
+    and ($2::timestamp is null
+        or (created_at, id) > ($2, $3::uuid))
+order by created_at asc, id asc
+limit $4
 `
 
-func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsByAuthor, userID)
+type ListChirpsAscParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	RowLimit       int32
+}
+
+// A page of chirps oldest first, starting after the (created_at, id) of the
+// last chirp on the previous page. A listing of one author leaves out their
+// pinned chirps, which are shown first on their own.
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -100,6 +352,17 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.TombstonedAt,
+			&i.LikeCount,
+			&i.ReshareOf,
+			&i.ReshareKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -114,22 +377,72 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 	return items, nil
 }
 
-const getChirpById = `-- name: GetChirpById :one
-select id, created_at, updated_at, body, user_id from chirps
-where id = $1
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+select id, created_at, updated_at, body, user_id, edited_at, in_reply_to, thread_id, tombstoned_at, like_count, reshare_of, reshare_kind, rechirp_count, quote_count, deleted_at, deleted_by from chirps
+where deleted_at is null
+    and not (reshare_kind = 'rechirp' and reshare_of is null)
+    and ($1::uuid is null or user_id = $1)
+    and ($1::uuid is null
+        or not exists (select 1 from pinned_chirps where pinned_chirps.chirp_id = chirps.id))
+    and ($2::timestamp is null
+        or (created_at, id) < ($2, $3::uuid))
+order by created_at desc, id desc
+limit $4
 `
 
-func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpById, id)
-	var i Chirp
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
+type ListChirpsDescParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	RowLimit       int32
+}
+
+// A page of chirps newest first, starting before the (created_at, id) of
+// the last chirp on the previous page. Pinned chirps are left out like in
+// ListChirpsAsc.
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.EditedAt,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.TombstonedAt,
+			&i.LikeCount,
+			&i.ReshareOf,
+			&i.ReshareKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const removeChirps = `-- name: RemoveChirps :exec
@@ -140,3 +453,14 @@ func (q *Queries) RemoveChirps(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, removeChirps)
 	return err
 }
+
+const tombstoneChirp = `-- name: TombstoneChirp :exec
+update chirps
+set body = '', updated_at = now(), tombstoned_at = now()
+where id = $1
+`
+
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, tombstoneChirp, id)
+	return err
+}
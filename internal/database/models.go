@@ -11,28 +11,212 @@ import (
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	BlobKey      string
+	ThumbnailKey string
+	Width        int32
+	Height       int32
+	SizeBytes    int32
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	EditedAt     sql.NullTime
+	InReplyTo    uuid.NullUUID
+	ThreadID     uuid.UUID
+	TombstonedAt sql.NullTime
+	LikeCount    int32
+	ReshareOf    uuid.NullUUID
+	ReshareKind  sql.NullString
+	RechirpCount int32
+	QuoteCount   int32
+	DeletedAt    sql.NullTime
+	DeletedBy    uuid.NullUUID
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type ChirpDraft struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Body      string
+	InReplyTo uuid.NullUUID
+	QuoteOf   uuid.NullUUID
+	PublishAt sql.NullTime
+	LastError sql.NullString
+}
+
+type ChirpHashtag struct {
+	ChirpID uuid.UUID
+	Tag     string
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
 	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
 }
 
-type RefreshToken struct {
-	Token     string
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type LoginThrottle struct {
+	ThrottleKey   string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+}
+
+type OauthRefreshToken struct {
+	TokenHash string
+	ClientID  uuid.UUID
 	UserID    uuid.UUID
-	ExpiredAt time.Time
+	Scopes    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
 	RevokedAt sql.NullTime
 }
 
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      string
+	ExpiresAt   sql.NullTime
+	LastUsedAt  sql.NullTime
+	RevokedAt   sql.NullTime
+}
+
+type PinnedChirp struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Position  int32
+	Label     string
+	VoteCount int32
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	TokenHash       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          uuid.UUID
+	ExpiredAt       time.Time
+	RevokedAt       sql.NullTime
+	FamilyID        uuid.UUID
+	ParentTokenHash sql.NullString
+	TokenPrefix     sql.NullString
+	LastUsedAt      sql.NullTime
+	UserAgent       sql.NullString
+	IpAddress       sql.NullString
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	TotpSecret      sql.NullString
+	TotpEnabled     bool
+	EmailVerifiedAt sql.NullTime
+	Role            string
+	Handle          sql.NullString
+	TotpLastStep    sql.NullInt64
 }
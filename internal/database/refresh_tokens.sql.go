@@ -7,58 +7,147 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-insert into refresh_tokens (token, created_at, updated_at, user_id, expired_at)
-values ($1, now(), now(), $2, $3)
-returning token, created_at, updated_at, user_id, expired_at, revoked_at
+insert into refresh_tokens (
+    token_hash, token_prefix, created_at, updated_at, user_id, expired_at,
+    family_id, parent_token_hash, last_used_at, user_agent, ip_address
+)
+values ($1, $2, now(), now(), $3, $4, $5, $6, now(), $7, $8)
+returning token_hash, created_at, updated_at, user_id, expired_at, revoked_at, family_id, parent_token_hash, token_prefix, last_used_at, user_agent, ip_address
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	UserID    uuid.UUID
-	ExpiredAt time.Time
+	TokenHash       string
+	TokenPrefix     sql.NullString
+	UserID          uuid.UUID
+	ExpiredAt       time.Time
+	FamilyID        uuid.UUID
+	ParentTokenHash sql.NullString
+	UserAgent       sql.NullString
+	IpAddress       sql.NullString
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiredAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.TokenHash,
+		arg.TokenPrefix,
+		arg.UserID,
+		arg.ExpiredAt,
+		arg.FamilyID,
+		arg.ParentTokenHash,
+		arg.UserAgent,
+		arg.IpAddress,
+	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiredAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ParentTokenHash,
+		&i.TokenPrefix,
+		&i.LastUsedAt,
+		&i.UserAgent,
+		&i.IpAddress,
 	)
 	return i, err
 }
 
+const getActiveSessions = `-- name: GetActiveSessions :many
+select
+    rt.family_id,
+    (select min(f.created_at) from refresh_tokens f where f.family_id = rt.family_id)::timestamp as started_at,
+    rt.last_used_at,
+    rt.user_agent,
+    rt.ip_address,
+    rt.expired_at
+from refresh_tokens rt
+where rt.user_id = $1 and rt.revoked_at is null and rt.expired_at > now()
+order by rt.last_used_at desc
+`
+
+type GetActiveSessionsRow struct {
+	FamilyID   uuid.UUID
+	StartedAt  time.Time
+	LastUsedAt sql.NullTime
+	UserAgent  sql.NullString
+	IpAddress  sql.NullString
+	ExpiredAt  time.Time
+}
+
+// a session is a token family, its one unrevoked token is the latest use
+func (q *Queries) GetActiveSessions(ctx context.Context, userID uuid.UUID) ([]GetActiveSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetActiveSessionsRow
+	for rows.Next() {
+		var i GetActiveSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.StartedAt,
+			&i.LastUsedAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.ExpiredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllRefreshTokens = `-- name: GetAllRefreshTokens :many
-select token, created_at, updated_at, user_id, expired_at, revoked_at from refresh_tokens
+select token_prefix, created_at, updated_at, user_id, expired_at, revoked_at, family_id
+from refresh_tokens
 order by created_at desc
 `
 
-func (q *Queries) GetAllRefreshTokens(ctx context.Context) ([]RefreshToken, error) {
+type GetAllRefreshTokensRow struct {
+	TokenPrefix sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	ExpiredAt   time.Time
+	RevokedAt   sql.NullTime
+	FamilyID    uuid.UUID
+}
+
+func (q *Queries) GetAllRefreshTokens(ctx context.Context) ([]GetAllRefreshTokensRow, error) {
 	rows, err := q.db.QueryContext(ctx, getAllRefreshTokens)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []RefreshToken
+	var items []GetAllRefreshTokensRow
 	for rows.Next() {
-		var i RefreshToken
+		var i GetAllRefreshTokensRow
 		if err := rows.Scan(
-			&i.Token,
+			&i.TokenPrefix,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.ExpiredAt,
 			&i.RevokedAt,
+			&i.FamilyID,
 		); err != nil {
 			return nil, err
 		}
@@ -73,25 +162,92 @@ func (q *Queries) GetAllRefreshTokens(ctx context.Context) ([]RefreshToken, erro
 	return items, nil
 }
 
+const getLegacyRefreshTokens = `-- name: GetLegacyRefreshTokens :many
+select token_hash from refresh_tokens
+where token_prefix is null
+`
+
+func (q *Queries) GetLegacyRefreshTokens(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getLegacyRefreshTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var token_hash string
+		if err := rows.Scan(&token_hash); err != nil {
+			return nil, err
+		}
+		items = append(items, token_hash)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRefeshToken = `-- name: GetRefeshToken :one
-select token, created_at, updated_at, user_id, expired_at, revoked_at from refresh_tokens
-where token = $1
+select token_hash, created_at, updated_at, user_id, expired_at, revoked_at, family_id, parent_token_hash, token_prefix, last_used_at, user_agent, ip_address from refresh_tokens
+where token_hash = $1
 `
 
-func (q *Queries) GetRefeshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefeshToken, token)
+func (q *Queries) GetRefeshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefeshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiredAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ParentTokenHash,
+		&i.TokenPrefix,
+		&i.LastUsedAt,
+		&i.UserAgent,
+		&i.IpAddress,
 	)
 	return i, err
 }
 
+const hashLegacyParentToken = `-- name: HashLegacyParentToken :exec
+update refresh_tokens
+set parent_token_hash = $1
+where parent_token_hash = $2
+`
+
+type HashLegacyParentTokenParams struct {
+	NewTokenHash sql.NullString
+	OldTokenHash sql.NullString
+}
+
+func (q *Queries) HashLegacyParentToken(ctx context.Context, arg HashLegacyParentTokenParams) error {
+	_, err := q.db.ExecContext(ctx, hashLegacyParentToken, arg.NewTokenHash, arg.OldTokenHash)
+	return err
+}
+
+const hashLegacyRefreshToken = `-- name: HashLegacyRefreshToken :exec
+update refresh_tokens
+set token_hash = $1, token_prefix = $2
+where token_hash = $3
+`
+
+type HashLegacyRefreshTokenParams struct {
+	NewTokenHash string
+	TokenPrefix  sql.NullString
+	OldTokenHash string
+}
+
+func (q *Queries) HashLegacyRefreshToken(ctx context.Context, arg HashLegacyRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, hashLegacyRefreshToken, arg.NewTokenHash, arg.TokenPrefix, arg.OldTokenHash)
+	return err
+}
+
 const removeAllRefreshTokens = `-- name: RemoveAllRefreshTokens :exec
 delete from refresh_tokens
 `
@@ -101,13 +257,81 @@ func (q *Queries) RemoveAllRefreshTokens(ctx context.Context) error {
 	return err
 }
 
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where user_id = $1 and revoked_at is null
+`
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, userID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 update refresh_tokens
 set updated_at = now(), revoked_at = now()
-where token = $1
+where token_hash = $1
+`
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshToken, tokenHash)
+	return err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where family_id = $1 and revoked_at is null
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
 	return err
 }
+
+const revokeSession = `-- name: RevokeSession :execrows
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where family_id = $1 and user_id = $2 and revoked_at is null
+`
+
+type RevokeSessionParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where token_hash = $1 and revoked_at is null
+returning token_hash, created_at, updated_at, user_id, expired_at, revoked_at, family_id, parent_token_hash, token_prefix, last_used_at, user_agent, ip_address
+`
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiredAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ParentTokenHash,
+		&i.TokenPrefix,
+		&i.LastUsedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+	)
+	return i, err
+}
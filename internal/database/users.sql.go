@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -14,7 +15,7 @@ import (
 const createUser = `-- name: CreateUser :one
 insert into users (id, created_at, updated_at, email, hashed_password)
 values (gen_random_uuid(), now(), now(), $1, $2)
-returning id, created_at, updated_at, email, hashed_password, is_chirpy_red
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
 `
 
 type CreateUserParams struct {
@@ -32,6 +33,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -40,7 +47,7 @@ const downgradeUser = `-- name: DowngradeUser :one
 update users 
 set is_chirpy_red = false
 where id = $1
-returning id, created_at, updated_at, email, hashed_password, is_chirpy_red
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
 `
 
 func (q *Queries) DowngradeUser(ctx context.Context, id uuid.UUID) (User, error) {
@@ -53,12 +60,18 @@ func (q *Queries) DowngradeUser(ctx context.Context, id uuid.UUID) (User, error)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-select id, created_at, updated_at, email, hashed_password, is_chirpy_red from users
+select id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step from users
 where email = $1
 `
 
@@ -72,6 +85,63 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserById = `-- name: GetUserById :one
+select id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step from users
+where id = $1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByIdForUpdate = `-- name: GetUserByIdForUpdate :one
+select id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step from users
+where id = $1
+for update
+`
+
+func (q *Queries) GetUserByIdForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIdForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -87,9 +157,12 @@ func (q *Queries) RemoveAllUsers(ctx context.Context) error {
 
 const updateUser = `-- name: UpdateUser :one
 update users
-set hashed_password = $2, email = $3, updated_at = now()
+set hashed_password = $2,
+    email = $3,
+    email_verified_at = case when email = $3 then email_verified_at else null end,
+    updated_at = now()
 where id = $1
-returning id, created_at, updated_at, email, hashed_password, is_chirpy_red
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
 `
 
 type UpdateUserParams struct {
@@ -108,6 +181,77 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const updateUserHandle = `-- name: UpdateUserHandle :one
+update users
+set handle = $2, updated_at = now()
+where id = $1
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
+`
+
+type UpdateUserHandleParams struct {
+	ID           uuid.UUID
+	Handle       sql.NullString
+	TotpLastStep sql.NullInt64
+}
+
+func (q *Queries) UpdateUserHandle(ctx context.Context, arg UpdateUserHandleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserHandle, arg.ID, arg.Handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+update users
+set role = $2, updated_at = now()
+where id = $1
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -116,7 +260,7 @@ const upgradeUserToRed = `-- name: UpgradeUserToRed :one
 update users 
 set is_chirpy_red = true
 where id = $1
-returning id, created_at, updated_at, email, hashed_password, is_chirpy_red
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
 `
 
 func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -129,6 +273,60 @@ func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+update users
+set hashed_password = $2, updated_at = now()
+where id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+update users
+set email_verified_at = now(), updated_at = now()
+where id = $1 and email = $2
+returning id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, email_verified_at, role, handle, totp_last_step
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Handle,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
@@ -0,0 +1,139 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages to users. The server only talks to this
+// interface, so tests and local development never need a real mail server.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// sends without a deadline on their context still give up after this long
+const defaultSendTimeout = time.Second * 30
+
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers msg the way smtp.SendMail does, except that the whole
+// conversation with the server has to finish before ctx is done, so a slow
+// or silent server can't hold on to the sender forever.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultSendTimeout)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return err
+	}
+	// cancelling ctx cuts the connection short too
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(m.From)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buildMessage(m.From, msg))
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// LogMailer writes every message to w instead of sending it, for local
+// development and tests.
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w, "---- %v ----\n%s\n", time.Now().UTC().Format(time.RFC3339), buildMessage("chirpy", msg))
+	return err
+}
+
+func buildMessage(from string, msg Message) []byte {
+	// strip line breaks so user supplied values cannot inject headers
+	clean := func(s string) string {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", clean(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", clean(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
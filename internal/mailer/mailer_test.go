@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Hello",
+		Body:    "some body",
+	})
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{"To: user@example.com", "Subject: Hello", "some body"} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("output is missing %q: %v", expected, out)
+		}
+	}
+}
+
+func TestBuildMessageStripsHeaderInjection(t *testing.T) {
+	msg := buildMessage("chirpy@example.com", Message{
+		To:      "user@example.com\r\nBcc: attacker@example.com",
+		Subject: "Hi\nX-Evil: yes",
+		Body:    "body",
+	})
+
+	headers := strings.SplitN(string(msg), "\r\n\r\n", 2)[0]
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Evil:") {
+			t.Fatalf("injected header made it into the message: %q", line)
+		}
+	}
+}
+
+func TestSMTPMailerGivesUpOnSilentServer(t *testing.T) {
+	// accepts the connection but never sends a greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second * 5)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	m := &SMTPMailer{Host: "127.0.0.1", Port: addr.Port, From: "chirpy@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	start := time.Now()
+	err = m.Send(ctx, Message{To: "user@example.com", Subject: "Hello", Body: "body"})
+	if err == nil {
+		t.Fatalf("expected an error from a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Fatalf("send took %v, expected it to stop at the context deadline", elapsed)
+	}
+}
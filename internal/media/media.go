@@ -0,0 +1,165 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxPixels keeps a small file that decodes to a huge image from
+	// eating all the memory. At 4 bytes a pixel that is 64MB per decode
+	MaxPixels = 16_000_000
+	// MaxConcurrentDecodes bounds the total, uploads beyond it wait their
+	// turn
+	MaxConcurrentDecodes = 2
+	ThumbnailSize        = 320
+	jpegQuality          = 85
+)
+
+var decodeSlots = make(chan struct{}, MaxConcurrentDecodes)
+
+var (
+	ErrUnsupportedType = errors.New("file must be a jpeg, png or gif image")
+	ErrTooManyPixels   = errors.New("image is too large")
+)
+
+// Image is an upload ready to store. Data and Thumbnail are re-encoded
+// from the decoded pixels, so nothing from the original file but the
+// picture survives, EXIF metadata included.
+type Image struct {
+	ContentType string
+	// Ext is the file extension for ContentType, with the dot
+	Ext       string
+	Data      []byte
+	Thumbnail []byte
+	Width     int
+	Height    int
+}
+
+// Process checks that data is an image by looking at its contents rather
+// than trusting the name or content type it was uploaded with, then strips
+// it and makes a thumbnail. Photos are turned the right way up first, as
+// the EXIF orientation that said how to show them is dropped. Animated
+// gifs keep only their first frame.
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	var decode func([]byte) (image.Image, error)
+	switch contentType {
+	case "image/jpeg":
+		decode = func(data []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(data)) }
+	case "image/png":
+		decode = func(data []byte) (image.Image, error) { return png.Decode(bytes.NewReader(data)) }
+	case "image/gif":
+		decode = func(data []byte) (image.Image, error) { return gif.Decode(bytes.NewReader(data)) }
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, ErrTooManyPixels
+	}
+
+	decodeSlots <- struct{}{}
+	defer func() { <-decodeSlots }()
+
+	decoded, err := decode(data)
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	img := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	thumbnail := shrink(img, ThumbnailSize)
+
+	processed := Image{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+	// gifs are stored as pngs, there is no point keeping the 256 colour
+	// palette once there is only one frame
+	if contentType == "image/jpeg" {
+		processed.ContentType, processed.Ext = "image/jpeg", ".jpg"
+		processed.Data, err = encodeJPEG(img)
+		if err == nil {
+			processed.Thumbnail, err = encodeJPEG(thumbnail)
+		}
+	} else {
+		processed.ContentType, processed.Ext = "image/png", ".png"
+		processed.Data, err = encodePNG(img)
+		if err == nil {
+			processed.Thumbnail, err = encodePNG(thumbnail)
+		}
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	return processed, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	return buf.Bytes(), err
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// shrink scales img down so neither side is longer than size, averaging
+// the pixels that fold into each new one. Smaller images are returned as
+// they are.
+func shrink(img *image.RGBA, size int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= size && height <= size {
+		return img
+	}
+	newWidth, newHeight := size, height*size/width
+	if height > width {
+		newWidth, newHeight = width*size/height, size
+	}
+	newWidth, newHeight = max(newWidth, 1), max(newHeight, 1)
+
+	thumb := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0, y1 := y*height/newHeight, max((y+1)*height/newHeight, y*height/newHeight+1)
+		for x := 0; x < newWidth; x++ {
+			x0, x1 := x*width/newWidth, max((x+1)*width/newWidth, x*width/newWidth+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[sy*img.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*thumb.Stride + x*4
+			for c := 0; c < 4; c++ {
+				thumb.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return thumb
+}
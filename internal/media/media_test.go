@@ -0,0 +1,126 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	// mark the top left corner so turns can be checked
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+// withExif puts an APP1 segment holding just an orientation tag straight
+// after the start of a jpeg.
+func withExif(jpg []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := []byte{0xff, 0xd8, 0xff, 0xe1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessJPEGStripsExifAndTurns(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(400, 200), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withExif(buf.Bytes(), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatal("test image should carry an orientation")
+	}
+
+	processed, err := Process(data)
+	if err != nil {
+		t.Fatalf("failed to process: %v", err)
+	}
+	if processed.ContentType != "image/jpeg" || processed.Ext != ".jpg" {
+		t.Fatalf("unexpected type %v %v", processed.ContentType, processed.Ext)
+	}
+	if bytes.Contains(processed.Data, []byte("Exif")) {
+		t.Fatal("exif should be stripped")
+	}
+	if processed.Width != 200 || processed.Height != 400 {
+		t.Fatalf("image should be turned on its side, got %vx%v", processed.Width, processed.Height)
+	}
+
+	thumb, err := jpeg.Decode(bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail does not decode: %v", err)
+	}
+	if thumb.Bounds().Dx() != 160 || thumb.Bounds().Dy() != ThumbnailSize {
+		t.Fatalf("unexpected thumbnail size %v", thumb.Bounds())
+	}
+}
+
+func TestProcessSmallPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(10, 20)); err != nil {
+		t.Fatal(err)
+	}
+	processed, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to process: %v", err)
+	}
+	if processed.ContentType != "image/png" || processed.Width != 10 || processed.Height != 20 {
+		t.Fatalf("unexpected result %v %vx%v", processed.ContentType, processed.Width, processed.Height)
+	}
+	thumb, err := png.Decode(bytes.NewReader(processed.Thumbnail))
+	if err != nil || thumb.Bounds().Dx() != 10 {
+		t.Fatalf("small images should keep their size as a thumbnail: %v", err)
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("<html><script>alert(1)</script></html>"),
+		[]byte("\x89PNG\r\n\x1a\nnot really a png"),
+		{},
+	}
+	for _, input := range inputs {
+		if _, err := Process(input); err != ErrUnsupportedType {
+			t.Errorf("Process(%q) = %v, want ErrUnsupportedType", input, err)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	img := testImage(3, 2)
+	red := color.RGBA{255, 0, 0, 255}
+	cases := map[int]image.Point{
+		1: {0, 0},
+		2: {2, 0},
+		3: {2, 1},
+		4: {0, 1},
+		5: {0, 0},
+		6: {1, 0},
+		7: {1, 2},
+		8: {0, 2},
+	}
+	for orientation, corner := range cases {
+		out := orient(img, orientation)
+		if out.RGBAAt(corner.X, corner.Y) != red {
+			t.Errorf("orientation %v: marked corner should be at %v", orientation, corner)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// jpegOrientation reads the EXIF orientation tag of a jpeg, 1 if there
+// isn't one.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xff {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		// the image data starts at SOS, metadata always comes before it
+		if marker == 0xda || length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// orient turns img so it shows the way the camera meant for the given
+// EXIF orientation.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	newWidth, newHeight := width, height
+	// 5 to 8 are turned a quarter, so the sides swap
+	if orientation >= 5 {
+		newWidth, newHeight = height, width
+	}
+	out := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var nx, ny int
+			switch orientation {
+			case 2:
+				nx, ny = width-1-x, y
+			case 3:
+				nx, ny = width-1-x, height-1-y
+			case 4:
+				nx, ny = x, height-1-y
+			case 5:
+				nx, ny = y, x
+			case 6:
+				nx, ny = height-1-y, x
+			case 7:
+				nx, ny = height-1-y, width-1-x
+			case 8:
+				nx, ny = y, width-1-x
+			}
+			copy(out.Pix[ny*out.Stride+nx*4:ny*out.Stride+nx*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+	return out
+}
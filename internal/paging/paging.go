@@ -0,0 +1,82 @@
+package paging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks where a page ended. It is handed to clients base64 encoded
+// so they treat it as opaque.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	// only set on search results, which are ordered by rank first
+	Rank *float32 `json:"r,omitempty"`
+}
+
+func (cursor Cursor) Encode() string {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor made by Encode. Anything else, including a
+// cursor that decodes but is missing the time or id, is ErrInvalidCursor.
+func DecodeCursor(cursor string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var decoded Cursor
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if decoded.CreatedAt.IsZero() || decoded.ID == uuid.Nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return decoded, nil
+}
+
+// Request is the limit and cursor query parameters shared by every chirp
+// listing. After is nil on the first page.
+type Request struct {
+	Limit int
+	After *Cursor
+}
+
+func ParseRequest(query url.Values) (Request, error) {
+	page := Request{Limit: DefaultLimit}
+	if limitString := query.Get("limit"); limitString != "" {
+		parsed, err := strconv.Atoi(limitString)
+		if err != nil || parsed < 1 || parsed > MaxLimit {
+			return Request{}, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		page.Limit = parsed
+	}
+
+	if cursorString := query.Get("cursor"); cursorString != "" {
+		cursor, err := DecodeCursor(cursorString)
+		if err != nil {
+			return Request{}, err
+		}
+		page.After = &cursor
+	}
+	return page, nil
+}
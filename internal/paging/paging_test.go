@@ -0,0 +1,93 @@
+package paging
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	rank := float32(0.25)
+	cases := []Cursor{
+		{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC), ID: uuid.New()},
+		{CreatedAt: time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC), ID: uuid.New(), Rank: &rank},
+	}
+	for _, c := range cases {
+		got, err := DecodeCursor(c.Encode())
+		if err != nil {
+			t.Errorf("DecodeCursor(%+v) failed: %v", c, err)
+			continue
+		}
+		if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID {
+			t.Errorf("DecodeCursor(%+v) = %+v", c, got)
+		}
+		if (got.Rank == nil) != (c.Rank == nil) || (got.Rank != nil && *got.Rank != *c.Rank) {
+			t.Errorf("DecodeCursor(%+v) lost the rank, got %v", c, got.Rank)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	cases := []string{
+		"not base64!",
+		encode("not json"),
+		encode(`{"t":"2025-01-02T03:04:05Z"}`),
+		encode(`{"id":"` + uuid.NewString() + `"}`),
+		encode(`{"t":"yesterday","id":"` + uuid.NewString() + `"}`),
+		encode(`{"t":"2025-01-02T03:04:05Z","id":"not-a-uuid"}`),
+		encode(`{"t":"2025-01-02T03:04:05Z","id":"` + uuid.NewString() + `","r":"high"}`),
+		encode(`{"t":"2025-01-02T03:04:05Z","id":"` + uuid.NewString() + `"} trailing`),
+	}
+	for _, c := range cases {
+		_, err := DecodeCursor(c)
+		if err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) = %v, want ErrInvalidCursor", c, err)
+		}
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), ID: uuid.New()}
+	cases := []struct {
+		query     string
+		wantLimit int
+		wantAfter bool
+		wantErr   bool
+	}{
+		{"", DefaultLimit, false, false},
+		{"limit=1", 1, false, false},
+		{"limit=100", MaxLimit, false, false},
+		{"limit=0", 0, false, true},
+		{"limit=101", 0, false, true},
+		{"limit=-5", 0, false, true},
+		{"limit=ten", 0, false, true},
+		{"limit=20&cursor=" + cursor.Encode(), 20, true, false},
+		{"cursor=garbage", 0, false, true},
+	}
+	for _, c := range cases {
+		query, err := url.ParseQuery(c.query)
+		if err != nil {
+			t.Fatalf("bad test query %q: %v", c.query, err)
+		}
+		got, err := ParseRequest(query)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseRequest(%q) error = %v, want error %v", c.query, err, c.wantErr)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+		if got.Limit != c.wantLimit || (got.After != nil) != c.wantAfter {
+			t.Errorf("ParseRequest(%q) = %+v, want limit %d and cursor %v", c.query, got, c.wantLimit, c.wantAfter)
+		}
+		if got.After != nil && got.After.ID != cursor.ID {
+			t.Errorf("ParseRequest(%q) decoded the wrong cursor: %+v", c.query, got.After)
+		}
+	}
+}
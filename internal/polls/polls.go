@@ -0,0 +1,43 @@
+package polls
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	MinOptions     = 2
+	MaxOptions     = 4
+	MaxLabelLength = 50
+	MinDuration    = time.Minute * 5
+	MaxDuration    = time.Hour * 24 * 7
+)
+
+// Validate checks the options and closing time of a new poll, as of now.
+// Options are trimmed in place, and two that differ only in case count as
+// the same option.
+func Validate(options []string, closesAt time.Time, now time.Time) error {
+	if len(options) < MinOptions || len(options) > MaxOptions {
+		return fmt.Errorf("a poll needs %d to %d options", MinOptions, MaxOptions)
+	}
+	seen := map[string]bool{}
+	for i, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > MaxLabelLength {
+			return fmt.Errorf("poll options must be 1 to %d characters", MaxLabelLength)
+		}
+		if seen[strings.ToLower(option)] {
+			return errors.New("poll options must be different")
+		}
+		seen[strings.ToLower(option)] = true
+		options[i] = option
+	}
+
+	untilClose := closesAt.Sub(now)
+	if untilClose < MinDuration || untilClose > MaxDuration {
+		return fmt.Errorf("a poll must close between %v and %v from now", MinDuration, MaxDuration)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package polls
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := now.Add(time.Hour * 24)
+	cases := []struct {
+		options  []string
+		closesAt time.Time
+		wantErr  bool
+		want     []string
+	}{
+		{[]string{"yes", "no"}, day, false, []string{"yes", "no"}},
+		{[]string{"  cats ", "dogs\n", "birds", "fish"}, day, false, []string{"cats", "dogs", "birds", "fish"}},
+		{[]string{"only one"}, day, true, nil},
+		{[]string{"a", "b", "c", "d", "e"}, day, true, nil},
+		{[]string{"yes", "   "}, day, true, nil},
+		{[]string{"yes", strings.Repeat("x", MaxLabelLength+1)}, day, true, nil},
+		{[]string{"yes", strings.Repeat("x", MaxLabelLength)}, day, false, []string{"yes", strings.Repeat("x", MaxLabelLength)}},
+		{[]string{"Yes", " yes"}, day, true, nil},
+		{[]string{"yes", "no"}, now.Add(MinDuration), false, []string{"yes", "no"}},
+		{[]string{"yes", "no"}, now.Add(MinDuration - time.Second), true, nil},
+		{[]string{"yes", "no"}, now.Add(MaxDuration), false, []string{"yes", "no"}},
+		{[]string{"yes", "no"}, now.Add(MaxDuration + time.Second), true, nil},
+		{[]string{"yes", "no"}, now.Add(-time.Hour), true, nil},
+	}
+	for _, c := range cases {
+		options := slices.Clone(c.options)
+		err := Validate(options, c.closesAt, now)
+		if (err != nil) != c.wantErr {
+			t.Errorf("Validate(%q, %v) = %v, want error %v", c.options, c.closesAt.Sub(now), err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && !slices.Equal(options, c.want) {
+			t.Errorf("Validate(%q) left options %q, want %q", c.options, options, c.want)
+		}
+	}
+}
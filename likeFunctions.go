@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+// viewerID returns who is looking at a public listing, if they sent a
+// valid token. Listings work the same without one.
+func viewerID(r *http.Request, cfg *apiConfig) uuid.NullUUID {
+	if r.Header.Get("Authorization") == "" && !auth.UsesSessionCookie(r.Header) {
+		return uuid.NullUUID{}
+	}
+	principal, err := cfg.authorizeRequest(r)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: principal.UserID, Valid: true}
+}
+
+// likedByViewer returns which of the chirps the viewer has liked, in one
+// query for the whole page.
+func likedByViewer(r *http.Request, cfg *apiConfig, viewer uuid.NullUUID, chirpIDs []uuid.UUID) map[uuid.UUID]bool {
+	liked := map[uuid.UUID]bool{}
+	if !viewer.Valid || len(chirpIDs) == 0 {
+		return liked
+	}
+	ids, err := cfg.db.GetLikedChirpIDs(r.Context(), database.GetLikedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		fmt.Println("unable to look up liked chirps")
+		fmt.Println(err)
+		return liked
+	}
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return liked
+}
+
+func markLikedChirps(r *http.Request, cfg *apiConfig, viewer uuid.NullUUID, chirps []Chirp) {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	liked := likedByViewer(r, cfg, viewer, ids)
+	for i := range chirps {
+		chirps[i].LikedByMe = liked[chirps[i].ID]
+	}
+}
+
+func handleLikeChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	chirp, err := cfg.db.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	// liking a chirp twice is not an error, it just stays liked
+	added, err := cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// read it back for the count the trigger just updated
+	chirp, err = cfg.db.GetChirpById(r.Context(), chirp.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	chirps := []Chirp{convertChirp(chirp)}
+	decorateChirps(r, cfg, chirps)
+	chirps[0].LikedByMe = true
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if added > 0 {
+		status = http.StatusCreated
+	}
+	makeJsonResponse(w, data, status)
+}
+
+func handleUnlikeChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	_, err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetUserLikes lists the chirps a user has liked, most recently liked
+// first, a page at a time like GET /api/chirps.
+func handleGetUserLikes(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the cursor is the time of the like, not of the chirp
+	rows, err := cfg.db.GetUserLikes(r.Context(), database.GetUserLikesParams{
+		UserID:        userID,
+		BeforeLikedAt: page.afterCreatedAt,
+		BeforeChirpID: page.afterID,
+		RowLimit:      int32(page.limit + 1),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	likes := ChirpPage{Chirps: []Chirp{}}
+	if len(rows) > page.limit {
+		rows = rows[:page.limit]
+		last := rows[len(rows)-1]
+		likes.NextCursor = encodeChirpCursor(database.Chirp{ID: last.Chirp.ID, CreatedAt: last.LikedAt})
+	}
+	for _, row := range rows {
+		likes.Chirps = append(likes.Chirps, convertChirp(row.Chirp))
+	}
+	decorateChirps(r, cfg, likes.Chirps)
+
+	data, err := json.Marshal(likes)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
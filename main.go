@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,7 +16,9 @@ import (
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/blobstore"
 	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/mailer"
 )
 
 func main() {
@@ -26,6 +30,125 @@ func main() {
 	apicfg.platform = os.Getenv("PLATFORM")
 	apicfg.tokenSecret = os.Getenv("TOKEN_SECRET")
 	apicfg.polkaKey = os.Getenv("POLKA_KEY")
+	// the refresh token key has to be its own secret, so a leaked token
+	// secret can't also be used to match stored refresh token hashes
+	apicfg.refreshTokenKey = os.Getenv("REFRESH_TOKEN_KEY")
+	if apicfg.refreshTokenKey == "" {
+		fmt.Println("REFRESH_TOKEN_KEY is not set")
+		os.Exit(1)
+	}
+
+	// with a key directory configured tokens are signed with the key named
+	// by JWT_SIGNING_KID, otherwise they fall back to HS256 with TOKEN_SECRET
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
+	if jwtKeysDir != "" {
+		keys, err := auth.LoadKeySet(jwtKeysDir, os.Getenv("JWT_SIGNING_KID"))
+		if err != nil {
+			fmt.Println("unable to load jwt signing keys")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		apicfg.jwtKeys = keys
+	} else {
+		apicfg.jwtKeys = auth.NewKeySet(auth.NewHMACKey("default", apicfg.tokenSecret))
+	}
+
+	apicfg.passwordPolicy = auth.DefaultPasswordPolicy
+	if algorithm := os.Getenv("PASSWORD_HASH"); algorithm != "" {
+		apicfg.passwordPolicy.Algorithm = algorithm
+	}
+	if costString := os.Getenv("BCRYPT_COST"); costString != "" {
+		cost, err := strconv.Atoi(costString)
+		if err != nil {
+			fmt.Println("BCRYPT_COST is not a number")
+			os.Exit(1)
+		}
+		apicfg.passwordPolicy.BcryptCost = cost
+	}
+	err := apicfg.passwordPolicy.Validate()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apicfg.dummyPasswordHash, err = auth.HashPassword("not a real password", apicfg.passwordPolicy)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apicfg.trustForwardedFor = os.Getenv("TRUST_X_FORWARDED_FOR") == "true"
+
+	apicfg.requireVerifiedEmail = os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	// how long after posting a chirp can still be edited
+	apicfg.chirpEditWindow = defaultChirpEditWindow
+	apicfg.chirpEditWindowRed = defaultChirpEditWindowRed
+	for env, window := range map[string]*time.Duration{
+		"CHIRP_EDIT_WINDOW":     &apicfg.chirpEditWindow,
+		"CHIRP_EDIT_WINDOW_RED": &apicfg.chirpEditWindowRed,
+	} {
+		if windowString := os.Getenv(env); windowString != "" {
+			duration, err := time.ParseDuration(windowString)
+			if err != nil {
+				fmt.Println(env + " is not a duration")
+				os.Exit(1)
+			}
+			*window = duration
+		}
+	}
+
+	apicfg.trashRetention = defaultTrashRetention
+	if retentionString := os.Getenv("CHIRP_TRASH_RETENTION"); retentionString != "" {
+		apicfg.trashRetention, err = time.ParseDuration(retentionString)
+		if err != nil || apicfg.trashRetention <= 0 {
+			fmt.Println("CHIRP_TRASH_RETENTION is not a duration")
+			os.Exit(1)
+		}
+	}
+
+	// cookies are Secure unless turned off for local development over http
+	apicfg.secureCookies = os.Getenv("COOKIE_SECURE") != "false"
+	apicfg.baseURL = os.Getenv("BASE_URL")
+	if apicfg.baseURL == "" {
+		apicfg.baseURL = "http://localhost:8080"
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = defaultMediaDir
+	}
+	localBlobs, err := blobstore.NewLocalStore(mediaDir, apicfg.baseURL+"/media")
+	if err != nil {
+		fmt.Println("unable to open media directory")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apicfg.blobs = localBlobs
+
+	// without an smtp server configured, emails are written to MAIL_LOG or
+	// printed so links can be followed during local development
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			fmt.Println("SMTP_PORT is not a number")
+			os.Exit(1)
+		}
+		apicfg.mailer = &mailer.SMTPMailer{
+			Host:     smtpHost,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	} else if mailLog := os.Getenv("MAIL_LOG"); mailLog != "" {
+		file, err := os.OpenFile(mailLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Println("unable to open mail log")
+			os.Exit(1)
+		}
+		apicfg.mailer = mailer.NewLogMailer(file)
+	} else {
+		apicfg.mailer = mailer.NewLogMailer(os.Stdout)
+	}
 
 	dbURL := os.Getenv("DB_URL")
 	fmt.Println(dbURL)
@@ -35,39 +158,121 @@ func main() {
 		os.Exit(1)
 	}
 
+	apicfg.dbConn = db
 	apicfg.db = database.New(db)
 	// fmt.Println(dbQueries)
 
+	err = hashLegacyRefreshTokens(context.Background(), &apicfg)
+	if err != nil {
+		fmt.Println("unable to hash legacy refresh tokens")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	mux := http.NewServeMux()
 	server := &http.Server{
-		Handler: mux,
+		Handler: apicfg.middlewareCSRF(mux),
 		Addr:    ":8080",
 	}
-	dir := http.Dir(".")
+	// only what is in static is public, never the working directory, which
+	// has .env and maybe signing keys in it
+	dir := http.Dir("static")
 
 	fileserverHandler := http.StripPrefix("/app", http.FileServer(dir))
 	mux.Handle("/app/", apicfg.middlewareMetricsInc(fileserverHandler))
+	mux.Handle("GET /media/", http.StripPrefix("/media", localBlobs.Handler()))
 	mux.HandleFunc("GET /api/healthz", handleHealthz)
+	mux.HandleFunc("GET /.well-known/jwks.json", apicfg.handleJWKS())
 	// mux.HandleFunc("POST /api/validate_chirp", handleValidateChirp)
-	mux.HandleFunc("GET /admin/metrics", apicfg.handleMetrics())
-	mux.HandleFunc("POST /admin/reset", apicfg.handleReset())
-	mux.HandleFunc("GET /admin/tokens", middlewareAddCfg(handleGetRefreshTokens, &apicfg))
+	mux.HandleFunc("GET /admin/metrics", apicfg.middlewareRequireAdmin(apicfg.handleMetrics()))
+	mux.HandleFunc("POST /admin/reset", apicfg.middlewareRequireAdmin(apicfg.handleReset()))
+	mux.HandleFunc("GET /admin/tokens", apicfg.middlewareRequireAdmin(middlewareAddCfg(handleGetRefreshTokens, &apicfg)))
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", apicfg.middlewareRequireAdmin(middlewareAddCfg(handleUnlockUser, &apicfg)))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", apicfg.middlewareRequireAdmin(middlewareAddCfg(handleSetUserRole, &apicfg)))
 
 	mux.HandleFunc("POST /api/users", middlewareAddCfg(handleAddUser, &apicfg))
-	mux.HandleFunc("PUT /api/users", middlewareAddCfg(handleUpdateUser, &apicfg))
+	mux.HandleFunc("PUT /api/users", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUpdateUser, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("PUT /api/users/handle", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUpdateHandle, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("GET /api/users/verify", middlewareAddCfg(handleVerifyEmail, &apicfg))
+	mux.HandleFunc("POST /api/users/verify/resend", apicfg.middlewareRequireScopes(middlewareAddCfg(handleResendVerification, &apicfg), auth.ScopeUserWrite))
 
 	mux.HandleFunc("POST /api/login", middlewareAddCfg(handleLogin, &apicfg))
+	mux.HandleFunc("POST /api/login/mfa", middlewareAddCfg(handleLoginMFA, &apicfg))
+
+	mux.HandleFunc("POST /api/users/mfa/totp", apicfg.middlewareRequireScopes(middlewareAddCfg(handleEnrollTOTP, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("POST /api/users/mfa/totp/confirm", apicfg.middlewareRequireScopes(middlewareAddCfg(handleConfirmTOTP, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("DELETE /api/users/mfa/totp", apicfg.middlewareRequireScopes(middlewareAddCfg(handleDisableTOTP, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("POST /api/users/mfa/recovery-codes", apicfg.middlewareRequireScopes(middlewareAddCfg(handleRegenerateRecoveryCodes, &apicfg), auth.ScopeUserWrite))
+
+	mux.HandleFunc("POST /api/password/forgot", middlewareAddCfg(handleForgotPassword, &apicfg))
+	mux.HandleFunc("POST /api/password/reset", middlewareAddCfg(handleResetPassword, &apicfg))
 
 	mux.HandleFunc("POST /api/refresh", middlewareAddCfg(handleRefresh, &apicfg))
 	mux.HandleFunc("POST /api/revoke", middlewareAddCfg(handleRevoke, &apicfg))
+	mux.HandleFunc("POST /api/logout-all", apicfg.middlewareRequireScopes(middlewareAddCfg(handleLogoutAll, &apicfg), auth.ScopeUserWrite))
+
+	mux.HandleFunc("POST /api/users/tokens", apicfg.middlewareRequireScopes(middlewareAddCfg(handleCreatePersonalToken, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("GET /api/users/tokens", apicfg.middlewareRequireFirstParty(middlewareAddCfg(handleGetPersonalTokens, &apicfg), auth.ScopeUserRead))
+	mux.HandleFunc("DELETE /api/users/tokens/{tokenID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleRevokePersonalToken, &apicfg), auth.ScopeUserWrite))
+
+	mux.HandleFunc("POST /api/oauth/clients", apicfg.middlewareRequireScopes(middlewareAddCfg(handleCreateOAuthClient, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("GET /api/oauth/clients", apicfg.middlewareRequireFirstParty(middlewareAddCfg(handleGetOAuthClients, &apicfg), auth.ScopeUserRead))
+	mux.HandleFunc("GET /api/oauth/clients/{clientID}", middlewareAddCfg(handleGetOAuthClientInfo, &apicfg))
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleDeleteOAuthClient, &apicfg), auth.ScopeUserWrite))
+	mux.HandleFunc("GET /api/oauth/authorize", middlewareAddCfg(handleOAuthAuthorize, &apicfg))
+	mux.HandleFunc("POST /api/oauth/authorize", middlewareAddCfg(handleOAuthConsent, &apicfg))
+	mux.HandleFunc("POST /api/oauth/token", middlewareAddCfg(handleOAuthToken, &apicfg))
+	mux.HandleFunc("POST /api/oauth/revoke", middlewareAddCfg(handleOAuthRevoke, &apicfg))
+	mux.HandleFunc("GET /api/me/oauth/grants", apicfg.middlewareRequireFirstParty(middlewareAddCfg(handleGetOAuthGrants, &apicfg), auth.ScopeUserRead))
+	mux.HandleFunc("DELETE /api/me/oauth/grants/{clientID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleRevokeOAuthGrant, &apicfg), auth.ScopeUserWrite))
+
+	mux.HandleFunc("GET /api/sessions", apicfg.middlewareRequireFirstParty(middlewareAddCfg(handleGetSessions, &apicfg), auth.ScopeUserRead))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleDeleteSession, &apicfg), auth.ScopeUserWrite))
 
 	mux.HandleFunc("GET /api/chirps", middlewareAddCfg(handleGetChirps, &apicfg))
 	mux.HandleFunc("GET /api/chirps/{chirpID}", middlewareAddCfg(handlGetChirpById, &apicfg))
-	mux.HandleFunc("POST /api/chirps", middlewareAddCfg(handleAddChirp, &apicfg))
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", middlewareAddCfg(handleDeleteChirp, &apicfg))
+	mux.HandleFunc("POST /api/chirps", apicfg.middlewareRequireScopes(middlewareAddCfg(handleAddChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleEditChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", middlewareAddCfg(handleGetChirpRevisions, &apicfg))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", middlewareAddCfg(handleGetChirpThread, &apicfg))
+	mux.HandleFunc("POST /api/drafts", apicfg.middlewareRequireScopes(middlewareAddCfg(handleCreateDraft, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/drafts", apicfg.middlewareRequireScopes(middlewareAddCfg(handleGetDrafts, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/drafts/{draftID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleGetDraft, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUpdateDraft, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleDeleteDraft, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apicfg.middlewareRequireScopes(middlewareAddCfg(handlePublishDraft, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/media", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUploadMedia, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/search", middlewareAddCfg(handleSearchChirps, &apicfg))
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", middlewareAddCfg(handleGetHashtagChirps, &apicfg))
+	mux.HandleFunc("GET /api/users/{userID}/mentions", middlewareAddCfg(handleGetUserMentions, &apicfg))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apicfg.middlewareRequireScopes(middlewareAddCfg(handleRechirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUndoRechirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apicfg.middlewareRequireScopes(middlewareAddCfg(handleVoteInPoll, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apicfg.middlewareRequireScopes(middlewareAddCfg(handleLikeChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUnlikeChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/users/{userID}/likes", middlewareAddCfg(handleGetUserLikes, &apicfg))
+	mux.HandleFunc("GET /api/me/bookmarks", apicfg.middlewareRequireFirstParty(middlewareAddCfg(handleGetBookmarks, &apicfg), auth.ScopeUserRead))
+	mux.HandleFunc("POST /api/me/bookmarks", apicfg.middlewareRequireScopes(middlewareAddCfg(handleAddBookmark, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/me/bookmarks/{chirpID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleDeleteBookmark, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", apicfg.middlewareRequireScopes(middlewareAddCfg(handlePinChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apicfg.middlewareRequireScopes(middlewareAddCfg(handleUnpinChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apicfg.middlewareRequireScopes(middlewareAddCfg(handleDeleteChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apicfg.middlewareRequireScopes(middlewareAddCfg(handleRestoreChirp, &apicfg), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/me/trash", apicfg.middlewareRequireScopes(middlewareAddCfg(handleGetTrash, &apicfg), auth.ScopeChirpsWrite))
 
 	mux.HandleFunc("POST /api/polka/webhooks", middlewareAddCfg(handlePolkaWebhook, &apicfg))
 
+	// publishes scheduled chirps as they come due and purges old deleted ones
+	schedulerInterval := defaultSchedulerInterval
+	if intervalString := os.Getenv("SCHEDULER_INTERVAL"); intervalString != "" {
+		schedulerInterval, err = time.ParseDuration(intervalString)
+		if err != nil || schedulerInterval <= 0 {
+			fmt.Println("SCHEDULER_INTERVAL is not a duration")
+			os.Exit(1)
+		}
+	}
+	go runScheduler(context.Background(), &apicfg, schedulerInterval)
+
 	err = server.ListenAndServe()
 	if err != nil {
 		fmt.Println("error starting server")
@@ -76,6 +281,8 @@ func main() {
 	}
 }
 
+const refreshTokenDuration = time.Hour * 24 * 60 // 60 days
+
 func handleHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
@@ -172,13 +379,17 @@ func middlewareAddCfg(
 }
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token,omitempty"`
-	RefreshToken string    `json:"refresh_token,omitempty"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Token         string    `json:"token,omitempty"`
+	RefreshToken  string    `json:"refresh_token,omitempty"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	Handle        *string   `json:"handle"`
 }
 
 type UserRequest struct {
@@ -195,7 +406,7 @@ func handleAddUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	hashedPassword, err := auth.HashPassword(userRequest.Password)
+	hashedPassword, err := auth.HashPassword(userRequest.Password, cfg.passwordPolicy)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
@@ -214,6 +425,12 @@ func handleAddUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		return
 	}
 
+	err = sendVerificationEmail(r.Context(), cfg, dbUser)
+	if err != nil {
+		fmt.Println("unable to send verification email")
+		fmt.Println(err)
+	}
+
 	data, err := json.Marshal(convertUser(dbUser))
 	if err != nil {
 		data := makeChirpError("cannot marshel database user")
@@ -226,11 +443,17 @@ func handleAddUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 
 func convertUser(dbUser database.User) User {
 	user := User{
-		ID:          dbUser.ID,
-		CreatedAt:   dbUser.CreatedAt,
-		UpdatedAt:   dbUser.UpdatedAt,
-		Email:       dbUser.Email,
-		IsChirpyRed: dbUser.IsChirpyRed,
+		ID:            dbUser.ID,
+		CreatedAt:     dbUser.CreatedAt,
+		UpdatedAt:     dbUser.UpdatedAt,
+		Email:         dbUser.Email,
+		IsChirpyRed:   dbUser.IsChirpyRed,
+		MFAEnabled:    dbUser.TotpEnabled,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+		Role:          dbUser.Role,
+	}
+	if dbUser.Handle.Valid {
+		user.Handle = &dbUser.Handle.String
 	}
 	return user
 }
@@ -240,28 +463,93 @@ func quickChirpError(w http.ResponseWriter, message string) {
 	makeJsonResponse(w, data, http.StatusInternalServerError)
 }
 
+type LoginRequest struct {
+	UserRequest
+	// browsers can ask for the tokens in HttpOnly cookies instead of the body
+	UseCookies bool `json:"use_cookies"`
+}
+
 func handleLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	userRequest := UserRequest{}
+	userRequest := LoginRequest{}
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&userRequest)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
 	}
-	user, err := cfg.db.GetUserByEmail(r.Context(), userRequest.Email)
+
+	accountKey := accountThrottleKey(userRequest.Email)
+	ipKey := ipThrottleKey(r, cfg)
+	lockedFor, err := loginLockedFor(r.Context(), cfg, accountKey, ipKey)
 	if err != nil {
-		data := makeChirpError(err.Error())
-		makeJsonResponse(w, data, http.StatusUnauthorized)
+		quickChirpError(w, err.Error())
 		return
 	}
-	err = auth.CheckPasswordHash(userRequest.Password, user.HashedPassword)
-	if err != nil {
-		data := makeChirpError(err.Error())
+	if lockedFor > 0 {
+		respondLoginLocked(w, lockedFor)
+		return
+	}
+
+	user, err := cfg.db.GetUserByEmail(r.Context(), userRequest.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		quickChirpError(w, err.Error())
+		return
+	}
+	userFound := err == nil
+
+	// unknown emails are still checked against a hash made with the current
+	// policy so they take as long to reject as a wrong password
+	hashedPassword := cfg.dummyPasswordHash
+	if userFound {
+		hashedPassword = user.HashedPassword
+	}
+	err = auth.CheckPasswordHash(userRequest.Password, hashedPassword)
+	if err != nil || !userFound {
+		recordLoginFailure(r.Context(), cfg, accountKey, auth.AccountLockout)
+		recordLoginFailure(r.Context(), cfg, ipKey, auth.IPLockout)
+		data := makeChirpError(loginFailedMessage)
 		makeJsonResponse(w, data, http.StatusUnauthorized)
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.tokenSecret, time.Hour)
+	// the password is known to be right here, so this is the only chance
+	// to move an old hash over to the current policy
+	if cfg.passwordPolicy.NeedsRehash(user.HashedPassword) {
+		hashedPassword, err := auth.HashPassword(userRequest.Password, cfg.passwordPolicy)
+		if err == nil {
+			err = cfg.db.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+				ID:             user.ID,
+				HashedPassword: hashedPassword,
+			})
+		}
+		if err != nil {
+			fmt.Println("unable to rehash password")
+			fmt.Println(err)
+		}
+	}
+
+	// with 2fa on, the failures so far only count as cleared once the code
+	// is right too, otherwise a known password would reset the lock on
+	// guessing codes
+	if user.TotpEnabled {
+		handleMFAChallenge(w, user, cfg)
+		return
+	}
+
+	err = cfg.db.ClearLoginThrottle(r.Context(), accountKey)
+	if err != nil {
+		fmt.Println("unable to clear login throttle")
+		fmt.Println(err)
+	}
+
+	respondWithLogin(w, r, cfg, user, userRequest.UseCookies)
+}
+
+// respondWithLogin issues a fresh access token and refresh token for a user
+// who has fully authenticated. With useCookies they are set as cookies and
+// left out of the body.
+func respondWithLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig, user database.User, useCookies bool) {
+	token, err := auth.MakeJWT(auth.NewPrincipal(user.ID, user.Role), cfg.jwtKeys, time.Hour)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
@@ -274,13 +562,28 @@ func handleLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		return
 	}
 	convUser.RefreshToken = refreshToken
+	// every login starts a new token family
 	args := database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiredAt: time.Now().Add(time.Hour * 24 * 60), // 60 days
+		TokenHash:   auth.HashRefreshToken(refreshToken, cfg.refreshTokenKey),
+		TokenPrefix: sql.NullString{String: auth.RefreshTokenPrefix(refreshToken), Valid: true},
+		UserID:      user.ID,
+		ExpiredAt:   time.Now().Add(refreshTokenDuration),
+		FamilyID:    uuid.New(),
+		UserAgent:   sql.NullString{String: r.UserAgent(), Valid: true},
+		IpAddress:   sql.NullString{String: clientIP(r, cfg), Valid: true},
 	}
 	cfg.db.CreateRefreshToken(r.Context(), args)
 
+	if useCookies {
+		err = setSessionCookies(w, cfg, token, refreshToken)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		convUser.Token = ""
+		convUser.RefreshToken = ""
+	}
+
 	data, err := json.Marshal(convUser)
 	if err != nil {
 		quickChirpError(w, err.Error())
@@ -291,6 +594,16 @@ func handleLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 	w.Write(data)
 }
 
+type RefreshTokenInfo struct {
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ExpiredAt time.Time  `json:"expired_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	FamilyID  uuid.UUID  `json:"family_id"`
+}
+
 func handleGetRefreshTokens(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 	tokens, err := cfg.db.GetAllRefreshTokens(r.Context())
 	if err != nil {
@@ -298,7 +611,23 @@ func handleGetRefreshTokens(w http.ResponseWriter, r *http.Request, cfg *apiConf
 		return
 	}
 
-	data, err := json.Marshal(tokens)
+	infos := []RefreshTokenInfo{}
+	for _, token := range tokens {
+		info := RefreshTokenInfo{
+			Prefix:    token.TokenPrefix.String,
+			CreatedAt: token.CreatedAt,
+			UpdatedAt: token.UpdatedAt,
+			UserID:    token.UserID,
+			ExpiredAt: token.ExpiredAt,
+			FamilyID:  token.FamilyID,
+		}
+		if token.RevokedAt.Valid {
+			info.RevokedAt = &token.RevokedAt.Time
+		}
+		infos = append(infos, info)
+	}
+
+	data, err := json.Marshal(infos)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
@@ -309,45 +638,150 @@ func handleGetRefreshTokens(w http.ResponseWriter, r *http.Request, cfg *apiConf
 	w.Write(data)
 }
 
+// hashLegacyRefreshTokens replaces refresh tokens that were stored in
+// plaintext before hashing was introduced with their keyed hash, so they
+// keep working for the clients that hold them.
+func hashLegacyRefreshTokens(ctx context.Context, cfg *apiConfig) error {
+	tokens, err := cfg.db.GetLegacyRefreshTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		tokenHash := auth.HashRefreshToken(token, cfg.refreshTokenKey)
+		err = cfg.db.HashLegacyRefreshToken(ctx, database.HashLegacyRefreshTokenParams{
+			NewTokenHash: tokenHash,
+			TokenPrefix:  sql.NullString{String: auth.RefreshTokenPrefix(token), Valid: true},
+			OldTokenHash: token,
+		})
+		if err != nil {
+			return err
+		}
+		err = cfg.db.HashLegacyParentToken(ctx, database.HashLegacyParentTokenParams{
+			NewTokenHash: sql.NullString{String: tokenHash, Valid: true},
+			OldTokenHash: sql.NullString{String: token, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(tokens) > 0 {
+		fmt.Printf("hashed %d legacy refresh tokens\n", len(tokens))
+	}
+	return nil
+}
+
 func handleRefresh(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	tokenString, err := auth.GetBearerToken(r.Header)
+	usesCookies := auth.UsesSessionCookie(r.Header)
+	tokenString, err := auth.GetRefreshToken(r.Header)
 	if err != nil {
 		data := makeChirpError(err.Error())
 		makeJsonResponse(w, data, http.StatusUnauthorized)
 		return
 	}
 
-	dbToken, err := cfg.db.GetRefeshToken(r.Context(), tokenString)
+	tokenHash := auth.HashRefreshToken(tokenString, cfg.refreshTokenKey)
+	dbToken, err := cfg.db.GetRefeshToken(r.Context(), tokenHash)
 	if err != nil {
 		data := makeChirpError(err.Error())
 		makeJsonResponse(w, data, http.StatusUnauthorized)
 		return
 	}
 
-	if dbToken.ExpiredAt.Before(time.Now()) {
-		data := makeChirpError("token is expired")
+	err = auth.CheckRefreshToken(dbToken.ExpiredAt, dbToken.RevokedAt.Valid)
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		// a spent token showing up again means it was copied, so nothing
+		// issued from the same login can be trusted anymore
+		cfg.db.RevokeRefreshTokenFamily(r.Context(), dbToken.FamilyID)
+	}
+	if err != nil {
+		data := makeChirpError(err.Error())
 		makeJsonResponse(w, data, http.StatusUnauthorized)
 		return
 	}
 
-	if dbToken.RevokedAt.Valid {
-		data := makeChirpError("token has been revoked")
+	// the old token is only spent if its replacement is saved too, so a
+	// failure in between can't log the user out
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// only one request can win the rotation, a concurrent one with the
+	// same token gets no rows back and is handled as reuse
+	_, err = qtx.RotateRefreshToken(r.Context(), dbToken.TokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		cfg.db.RevokeRefreshTokenFamily(r.Context(), dbToken.FamilyID)
+		data := makeChirpError(auth.ErrRefreshTokenReused.Error())
 		makeJsonResponse(w, data, http.StatusUnauthorized)
 		return
 	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	args := database.CreateRefreshTokenParams{
+		TokenHash:       auth.HashRefreshToken(refreshToken, cfg.refreshTokenKey),
+		TokenPrefix:     sql.NullString{String: auth.RefreshTokenPrefix(refreshToken), Valid: true},
+		UserID:          dbToken.UserID,
+		ExpiredAt:       time.Now().Add(refreshTokenDuration),
+		FamilyID:        dbToken.FamilyID,
+		ParentTokenHash: sql.NullString{String: dbToken.TokenHash, Valid: true},
+		UserAgent:       sql.NullString{String: r.UserAgent(), Valid: true},
+		IpAddress:       sql.NullString{String: clientIP(r, cfg), Valid: true},
+	}
+	_, err = qtx.CreateRefreshToken(r.Context(), args)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
 
-	accessToken, err := auth.MakeJWT(dbToken.UserID, cfg.tokenSecret, time.Hour)
+	// look the user up again so a role change applies from the next refresh
+	user, err := cfg.db.GetUserById(r.Context(), dbToken.UserID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	accessToken, err := auth.MakeJWT(auth.NewPrincipal(user.ID, user.Role), cfg.jwtKeys, time.Hour)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
 	}
 
 	type JsonToken struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	jsonToken := JsonToken{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	}
+	if usesCookies {
+		err = setSessionCookies(w, cfg, accessToken, refreshToken)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
 
 	data, err := json.Marshal(jsonToken)
@@ -362,14 +796,18 @@ func handleRefresh(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 }
 
 func handleRevoke(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	tokenString, err := auth.GetBearerToken(r.Header)
+	if auth.UsesSessionCookie(r.Header) {
+		clearSessionCookies(w, cfg)
+	}
+	tokenString, err := auth.GetRefreshToken(r.Header)
 	if err != nil {
 		data := makeChirpError(err.Error())
 		makeJsonResponse(w, data, http.StatusUnauthorized)
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(r.Context(), tokenString)
+	tokenHash := auth.HashRefreshToken(tokenString, cfg.refreshTokenKey)
+	err = cfg.db.RevokeRefreshToken(r.Context(), tokenHash)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
@@ -379,7 +817,7 @@ func handleRevoke(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 }
 
 func handleUpdateUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	userID, err := auth.AuthorizeUser(r.Header, cfg.tokenSecret)
+	userID, err := auth.UserFromContext(r.Context())
 	if err != nil {
 		errData := makeChirpError(err.Error())
 		makeJsonResponse(w, errData, http.StatusUnauthorized)
@@ -399,7 +837,13 @@ func handleUpdateUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(updateUser.Password)
+	hashedPassword, err := auth.HashPassword(updateUser.Password, cfg.passwordPolicy)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	oldUser, err := cfg.db.GetUserById(r.Context(), userID)
 	if err != nil {
 		quickChirpError(w, err.Error())
 		return
@@ -417,6 +861,15 @@ func handleUpdateUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		return
 	}
 
+	// a new address is unverified until the link sent to it is opened
+	if dbUser.Email != oldUser.Email {
+		err = sendVerificationEmail(r.Context(), cfg, dbUser)
+		if err != nil {
+			fmt.Println("unable to send verification email")
+			fmt.Println(err)
+		}
+	}
+
 	data, err := json.Marshal(convertUser(dbUser))
 	if err != nil {
 		quickChirpError(w, err.Error())
@@ -437,7 +890,7 @@ func handlePolkaWebhook(w http.ResponseWriter, r *http.Request, cfg *apiConfig)
 	}
 
 	type PolkaRequest struct {
-		Event string `json:"event:`
+		Event string `json:"event"`
 		Data  struct {
 			UserID string `json:"user_id"`
 		} `json:"data"`
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	mfaChallengeDuration = time.Minute * 5
+	recoveryCodeCount    = 10
+	totpIssuer           = "Chirpy"
+)
+
+type MFACode struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func handleMFAChallenge(w http.ResponseWriter, user database.User, cfg *apiConfig) {
+	type MFAChallenge struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+
+	challenge, err := auth.MakeMFAChallenge(user.ID, cfg.jwtKeys, mfaChallengeDuration)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	data, err := json.Marshal(MFAChallenge{MFARequired: true, MFAToken: challenge})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleLoginMFA(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type MFALogin struct {
+		MFAToken   string `json:"mfa_token"`
+		Code       string `json:"code"`
+		UseCookies bool   `json:"use_cookies"`
+	}
+
+	var mfaLogin MFALogin
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&mfaLogin)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	userID, err := auth.ValidateMFAChallenge(mfaLogin.MFAToken, cfg.jwtKeys)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	ok := checkThrottledCode(w, r, cfg, user, func() bool {
+		return checkSecondFactor(r.Context(), cfg, user, mfaLogin.Code)
+	})
+	if !ok {
+		return
+	}
+
+	respondWithLogin(w, r, cfg, user, mfaLogin.UseCookies)
+}
+
+func handleEnrollTOTP(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if user.TotpEnabled {
+		errData := makeChirpError("two factor authentication is already enabled")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// the secret stays pending until a code from it has been confirmed
+	err = cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	type TOTPEnrollment struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+
+	data, err := json.Marshal(TOTPEnrollment{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(secret, totpIssuer, user.Email),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+func handleConfirmTOTP(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var mfaCode MFACode
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&mfaCode)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if user.TotpEnabled || !user.TotpSecret.Valid {
+		errData := makeChirpError("no pending two factor enrollment")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+
+	ok := checkThrottledCode(w, r, cfg, user, func() bool {
+		return useTOTPCode(r.Context(), cfg, user, mfaCode.Code)
+	})
+	if !ok {
+		return
+	}
+
+	err = cfg.db.EnableTOTP(r.Context(), user.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	respondWithRecoveryCodes(w, r, cfg, user.ID)
+}
+
+func handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if !user.TotpEnabled {
+		errData := makeChirpError("two factor authentication is not enabled")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+
+	respondWithRecoveryCodes(w, r, cfg, user.ID)
+}
+
+func handleDisableTOTP(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var mfaCode MFACode
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&mfaCode)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if !user.TotpEnabled {
+		errData := makeChirpError("two factor authentication is not enabled")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+
+	// a stolen access token alone should not be enough to turn 2fa off
+	ok := checkThrottledCode(w, r, cfg, user, func() bool {
+		return checkSecondFactor(r.Context(), cfg, user, mfaCode.Code)
+	})
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.DisableTOTP(r.Context(), user.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = qtx.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSecondFactor accepts either a current TOTP code or one of the user's
+// unused recovery codes, which is spent by this call.
+func checkSecondFactor(ctx context.Context, cfg *apiConfig, user database.User, code string) bool {
+	if !user.TotpEnabled || !user.TotpSecret.Valid {
+		return false
+	}
+
+	if useTOTPCode(ctx, cfg, user, code) {
+		return true
+	}
+
+	_, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: auth.HashRecoveryCode(code),
+	})
+	return err == nil
+}
+
+// useTOTPCode accepts a code from the user's totp secret, enabled or still
+// pending. Each code only works once, a code that was already used, or one
+// older than the last code used, is refused.
+func useTOTPCode(ctx context.Context, cfg *apiConfig, user database.User, code string) bool {
+	if !user.TotpSecret.Valid {
+		return false
+	}
+
+	step, ok := auth.MatchTOTPCode(code, user.TotpSecret.String, time.Now())
+	if !ok {
+		return false
+	}
+	used, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		Step: step,
+		ID:   user.ID,
+	})
+	if err != nil {
+		fmt.Println("unable to record totp step")
+		fmt.Println(err)
+		return false
+	}
+	return used > 0
+}
+
+// checkThrottledCode runs check under the same account lock as the password,
+// otherwise six digit codes could be guessed. It writes the error response
+// itself and only returns true once check has passed.
+func checkThrottledCode(w http.ResponseWriter, r *http.Request, cfg *apiConfig, user database.User, check func() bool) bool {
+	accountKey := accountThrottleKey(user.Email)
+	lockedFor, err := loginLockedFor(r.Context(), cfg, accountKey)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return false
+	}
+	if lockedFor > 0 {
+		respondLoginLocked(w, lockedFor)
+		return false
+	}
+
+	if !check() {
+		recordLoginFailure(r.Context(), cfg, accountKey, auth.AccountLockout)
+		errData := makeChirpError("invalid authentication code")
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return false
+	}
+
+	err = cfg.db.ClearLoginThrottle(r.Context(), accountKey)
+	if err != nil {
+		fmt.Println("unable to clear login throttle")
+		fmt.Println(err)
+	}
+	return true
+}
+
+// respondWithRecoveryCodes replaces all of the user's recovery codes and
+// sends back the new ones. They are only stored hashed, so this response is
+// the only time the user can see them.
+func respondWithRecoveryCodes(w http.ResponseWriter, r *http.Request, cfg *apiConfig, userID uuid.UUID) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	for _, code := range codes {
+		err = qtx.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	data, err := json.Marshal(RecoveryCodes{RecoveryCodes: codes})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	authorizationCodeDuration = time.Minute * 10
+	oauthAccessTokenDuration  = time.Hour
+	oauthConsentPage          = "/app/oauth/consent.html"
+)
+
+// OAuthClient is a third party app registered by a user. ClientSecret is
+// only filled in on the response that creates it.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Confidential bool      `json:"confidential"`
+	RedirectURIs []string  `json:"redirect_uris"`
+}
+
+func convertOAuthClient(dbClient database.OauthClient) OAuthClient {
+	client := OAuthClient{
+		ID:           dbClient.ID,
+		CreatedAt:    dbClient.CreatedAt,
+		Name:         dbClient.Name,
+		Confidential: dbClient.SecretHash.Valid,
+		RedirectURIs: dbClient.RedirectUris,
+	}
+	return client
+}
+
+// OAuthAuthorizeRequest holds the parameters of an authorization request.
+// They arrive in the query string and are posted back by the consent page.
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// oauthError writes an error in the shape RFC 6749 section 5.2 asks for,
+// which OAuth2 client libraries know how to read.
+func oauthError(w http.ResponseWriter, code, description string, status int) {
+	type OAuthErrorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+	data, err := json.Marshal(OAuthErrorResponse{Error: code, ErrorDescription: description})
+	if err != nil {
+		data = []byte{}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	makeJsonResponse(w, data, status)
+}
+
+// oauthRedirect adds values to the client's redirect uri, keeping any query
+// it was registered with.
+func oauthRedirect(redirectURI string, values url.Values) string {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := parsed.Query()
+	for key := range values {
+		query.Set(key, values.Get(key))
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func validRedirectURI(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" || parsed.Host == "" {
+		return false
+	}
+	if parsed.Scheme == "https" {
+		return true
+	}
+	// plain http is only allowed for apps running on the user's machine
+	host := parsed.Hostname()
+	return parsed.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1")
+}
+
+// checkAuthorizeRequest validates an authorization request. An error means
+// the client or redirect uri can't be trusted, so nothing should be sent
+// to the redirect uri. Otherwise a non-empty oauth error code is a problem
+// to report back to the client through the redirect uri.
+func checkAuthorizeRequest(ctx context.Context, cfg *apiConfig, req OAuthAuthorizeRequest) (database.OauthClient, []string, string, error) {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		return database.OauthClient{}, nil, "", fmt.Errorf("unknown client")
+	}
+	client, err := cfg.db.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return database.OauthClient{}, nil, "", fmt.Errorf("unknown client")
+	}
+	// the redirect uri must match a registered one exactly
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return database.OauthClient{}, nil, "", fmt.Errorf("redirect uri is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return client, nil, "unsupported_response_type", nil
+	}
+	// PKCE is required for every client, not just public ones
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return client, nil, "invalid_request", nil
+	}
+	scopes := auth.SplitScopes(req.Scope)
+	if len(scopes) == 0 {
+		return client, nil, "invalid_scope", nil
+	}
+	for _, scope := range scopes {
+		if !auth.DelegableScope(scope) {
+			return client, nil, "invalid_scope", nil
+		}
+	}
+	return client, scopes, "", nil
+}
+
+func handleCreateOAuthClient(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type OAuthClientRequest struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var clientRequest OAuthClientRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&clientRequest)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(clientRequest.Name)
+	if name == "" {
+		errData := makeChirpError("client name is required")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if len(clientRequest.RedirectURIs) == 0 {
+		errData := makeChirpError("at least one redirect uri is required")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	for _, redirectURI := range clientRequest.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			errData := makeChirpError("invalid redirect uri " + redirectURI)
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// public clients, like mobile and single page apps, can't keep a secret
+	secret := ""
+	secretHash := sql.NullString{}
+	if clientRequest.Confidential {
+		secret, err = auth.MakeOAuthToken()
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashOAuthToken(secret), Valid: true}
+	}
+
+	dbClient, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		UserID:       userID,
+		Name:         name,
+		SecretHash:   secretHash,
+		RedirectUris: clientRequest.RedirectURIs,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	client := convertOAuthClient(dbClient)
+	client.ClientSecret = secret
+
+	data, err := json.Marshal(client)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+func handleGetOAuthClients(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	dbClients, err := cfg.db.GetOAuthClientsForUser(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	clients := []OAuthClient{}
+	for _, dbClient := range dbClients {
+		clients = append(clients, convertOAuthClient(dbClient))
+	}
+
+	data, err := json.Marshal(clients)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+// handleGetOAuthClientInfo is public so the consent page can show the user
+// which app is asking.
+func handleGetOAuthClientInfo(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type OAuthClientInfo struct {
+		ID   uuid.UUID `json:"client_id"`
+		Name string    `json:"name"`
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		errData := makeChirpError("client not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(OAuthClientInfo{ID: client.ID, Name: client.Name})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleDeleteOAuthClient(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	// codes and refresh tokens issued to the client go with it
+	deleted, err := cfg.db.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:     clientID,
+		UserID: userID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if deleted == 0 {
+		errData := makeChirpError("client not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OAuthGrant is an app the user has let act for them, with every scope it
+// holds across its refresh tokens.
+type OAuthGrant struct {
+	ClientID   uuid.UUID `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func handleGetOAuthGrants(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := cfg.db.GetOAuthGrantsForUser(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	grants := []OAuthGrant{}
+	for _, row := range rows {
+		scopes := auth.SplitScopes(row.Scopes)
+		slices.Sort(scopes)
+		grants = append(grants, OAuthGrant{
+			ClientID:   row.ClientID,
+			ClientName: row.ClientName,
+			Scopes:     slices.Compact(scopes),
+			GrantedAt:  row.GrantedAt,
+			LastUsedAt: row.LastUsedAt,
+		})
+	}
+
+	data, err := json.Marshal(grants)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+// handleRevokeOAuthGrant signs an app out. It can ask again, but has to go
+// through the consent page to get back in.
+func handleRevokeOAuthGrant(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{
+		UserID:   userID,
+		ClientID: clientID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if revoked == 0 {
+		errData := makeChirpError("grant not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleOAuthAuthorize is where a third party app sends the user. Once the
+// request checks out the user is passed on to the consent page with the
+// same parameters.
+func handleOAuthAuthorize(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	query := r.URL.Query()
+	req := OAuthAuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	_, _, errCode, err := checkAuthorizeRequest(r.Context(), cfg, req)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if errCode != "" {
+		values := url.Values{"error": {errCode}}
+		if req.State != "" {
+			values.Set("state", req.State)
+		}
+		http.Redirect(w, r, oauthRedirect(req.RedirectURI, values), http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, oauthConsentPage+"?"+query.Encode(), http.StatusFound)
+}
+
+// handleOAuthConsent records the user's answer on the consent page and
+// tells the page where to send them back to.
+func handleOAuthConsent(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type OAuthConsent struct {
+		OAuthAuthorizeRequest
+		Approve bool `json:"approve"`
+	}
+
+	// only a token from logging in to Chirpy itself can grant access, not
+	// one that was handed to a script or another app
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+	principal, err := auth.ValidateJWT(tokenString, cfg.jwtKeys)
+	if err != nil || principal.ClientID != "" {
+		errData := makeChirpError("a first party login is required")
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var consent OAuthConsent
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&consent)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	client, scopes, errCode, err := checkAuthorizeRequest(r.Context(), cfg, consent.OAuthAuthorizeRequest)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if errCode == "" && !consent.Approve {
+		errCode = "access_denied"
+	}
+
+	values := url.Values{}
+	if consent.State != "" {
+		values.Set("state", consent.State)
+	}
+	if errCode != "" {
+		values.Set("error", errCode)
+	} else {
+		code, err := auth.MakeOAuthToken()
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		err = cfg.db.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+			CodeHash:      auth.HashOAuthToken(code),
+			ClientID:      client.ID,
+			UserID:        principal.UserID,
+			RedirectUri:   consent.RedirectURI,
+			Scopes:        auth.JoinScopes(scopes),
+			CodeChallenge: consent.CodeChallenge,
+			ExpiresAt:     time.Now().Add(authorizationCodeDuration),
+		})
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		values.Set("code", code)
+	}
+
+	type ConsentRedirect struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	data, err := json.Marshal(ConsentRedirect{RedirectTo: oauthRedirect(consent.RedirectURI, values)})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+// authenticateOAuthClient finds the client making a token or revocation
+// request. Confidential clients must send their secret, with HTTP basic
+// auth or in the form.
+func authenticateOAuthClient(r *http.Request, cfg *apiConfig) (database.OauthClient, error) {
+	clientIDString, secret, ok := r.BasicAuth()
+	if !ok {
+		clientIDString = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(clientIDString)
+	if err != nil {
+		return database.OauthClient{}, fmt.Errorf("unknown client")
+	}
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, fmt.Errorf("unknown client")
+	}
+
+	if client.SecretHash.Valid && !auth.CheckOAuthSecret(secret, client.SecretHash.String) {
+		return database.OauthClient{}, fmt.Errorf("invalid client secret")
+	}
+	return client, nil
+}
+
+func handleOAuthToken(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	err := r.ParseForm()
+	if err != nil {
+		oauthError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, err := authenticateOAuthClient(r, cfg)
+	if err != nil {
+		oauthError(w, "invalid_client", err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.db.UseAuthorizationCode(r.Context(), auth.HashOAuthToken(r.PostForm.Get("code")))
+		if errors.Is(err, sql.ErrNoRows) {
+			oauthError(w, "invalid_grant", "code is invalid, expired or already used", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+			oauthError(w, "invalid_grant", "code was not issued for this client", http.StatusBadRequest)
+			return
+		}
+		if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			oauthError(w, "invalid_grant", "code verifier does not match", http.StatusBadRequest)
+			return
+		}
+		tokens, err := issueOAuthTokens(r.Context(), cfg, cfg.db, client.ID, code.UserID, code.Scopes)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		respondWithOAuthTokens(w, tokens)
+
+	case "refresh_token":
+		tokenHash := auth.HashOAuthToken(r.PostForm.Get("refresh_token"))
+
+		// the old token is only spent if its replacement is saved too, so a
+		// failure in between can't leave the app signed out
+		tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		defer tx.Rollback()
+		qtx := cfg.db.WithTx(tx)
+
+		// refresh tokens are single use, like the first party ones
+		token, err := qtx.RotateOAuthRefreshToken(r.Context(), database.RotateOAuthRefreshTokenParams{
+			TokenHash: tokenHash,
+			ClientID:  client.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			revokeReplayedOAuthGrant(r.Context(), cfg, tokenHash, client.ID)
+			oauthError(w, "invalid_grant", "refresh token is invalid, expired or revoked", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		tokens, err := issueOAuthTokens(r.Context(), cfg, qtx, client.ID, token.UserID, token.Scopes)
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		err = tx.Commit()
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		respondWithOAuthTokens(w, tokens)
+
+	default:
+		oauthError(w, "unsupported_grant_type", "", http.StatusBadRequest)
+	}
+}
+
+// revokeReplayedOAuthGrant signs the app out for the user when a refresh
+// token that was already spent or revoked comes back. Someone else has a
+// copy of it, so nothing issued from the same grant can be trusted.
+func revokeReplayedOAuthGrant(ctx context.Context, cfg *apiConfig, tokenHash string, clientID uuid.UUID) {
+	token, err := cfg.db.GetOAuthRefreshToken(ctx, database.GetOAuthRefreshTokenParams{
+		TokenHash: tokenHash,
+		ClientID:  clientID,
+	})
+	if err != nil || !token.RevokedAt.Valid {
+		return
+	}
+	_, err = cfg.db.RevokeOAuthGrant(ctx, database.RevokeOAuthGrantParams{
+		UserID:   token.UserID,
+		ClientID: clientID,
+	})
+	if err != nil {
+		fmt.Println("unable to revoke replayed oauth grant")
+		fmt.Println(err)
+	}
+}
+
+type OAuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// issueOAuthTokens makes an access token and a refresh token for a client
+// and saves the refresh token with q. The access token is an ordinary
+// Chirpy JWT, limited to the granted scopes and marked with the client it
+// was issued to.
+func issueOAuthTokens(ctx context.Context, cfg *apiConfig, q *database.Queries, clientID, userID uuid.UUID, scope string) (OAuthTokens, error) {
+	user, err := q.GetUserById(ctx, userID)
+	if err != nil {
+		return OAuthTokens{}, err
+	}
+
+	principal := auth.NewScopedPrincipal(user.ID, user.Role, scope)
+	principal.ClientID = clientID.String()
+	accessToken, err := auth.MakeJWT(principal, cfg.jwtKeys, oauthAccessTokenDuration)
+	if err != nil {
+		return OAuthTokens{}, err
+	}
+
+	refreshToken, err := auth.MakeOAuthToken()
+	if err != nil {
+		return OAuthTokens{}, err
+	}
+	err = q.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		TokenHash: auth.HashOAuthToken(refreshToken),
+		ClientID:  clientID,
+		UserID:    user.ID,
+		Scopes:    scope,
+		ExpiresAt: time.Now().Add(refreshTokenDuration),
+	})
+	if err != nil {
+		return OAuthTokens{}, err
+	}
+
+	return OAuthTokens{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        auth.JoinScopes(principal.Scopes),
+	}, nil
+}
+
+func respondWithOAuthTokens(w http.ResponseWriter, tokens OAuthTokens) {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+// handleOAuthRevoke follows RFC 7009. Refresh tokens are revoked; access
+// tokens are JWTs and can't be, they run out within the hour. Unknown
+// tokens still get a 200 so clients can't probe for valid ones.
+func handleOAuthRevoke(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	err := r.ParseForm()
+	if err != nil {
+		oauthError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, err := authenticateOAuthClient(r, cfg)
+	if err != nil {
+		oauthError(w, "invalid_client", err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	err = cfg.db.RevokeOAuthRefreshToken(r.Context(), database.RevokeOAuthRefreshTokenParams{
+		TokenHash: auth.HashOAuthToken(r.PostForm.Get("token")),
+		ClientID:  client.ID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
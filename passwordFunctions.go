@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/mailer"
+)
+
+const (
+	passwordResetDuration = time.Hour
+	// a user is sent at most one working link in this long, so asking again
+	// and again can't flood their inbox or keep killing the link they have
+	passwordResetCooldown = time.Minute * 10
+)
+
+func handleForgotPassword(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type ForgotPassword struct {
+		Email string `json:"email"`
+	}
+
+	var forgotPassword ForgotPassword
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&forgotPassword)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	ipKey := passwordResetThrottleKey(r, cfg)
+	lockedFor, err := loginLockedFor(r.Context(), cfg, ipKey)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if lockedFor > 0 {
+		respondThrottled(w, lockedFor, "too many password reset requests, try again later")
+		return
+	}
+	recordLoginFailure(r.Context(), cfg, ipKey, auth.PasswordResetLockout)
+
+	// the response is the same whether or not the account exists, so this
+	// endpoint cannot be used to find out who is signed up
+	user, err := cfg.db.GetUserByEmail(r.Context(), forgotPassword.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	fresh, err := cfg.db.HasFreshPasswordResetToken(r.Context(), database.HasFreshPasswordResetTokenParams{
+		UserID:    user.ID,
+		SentSince: time.Now().Add(-passwordResetCooldown),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if fresh {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := auth.MakeEmailToken()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// only the newest link sent to a user should work
+	err = cfg.db.InvalidatePasswordResetTokens(r.Context(), user.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = cfg.db.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashEmailToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetDuration),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Use this link within the next hour to choose a new one:\n%v/app/reset.html?token=%v\n\n"+
+			"If this wasn't you, you can ignore this email.\n", cfg.baseURL, url.QueryEscape(token)),
+	}
+	sendMailInBackground(cfg, msg)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func handleResetPassword(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type ResetPassword struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	var resetPassword ResetPassword
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&resetPassword)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if resetPassword.Password == "" {
+		errData := makeChirpError("password cannot be empty")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(resetPassword.Password, cfg.passwordPolicy)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	userID, err := qtx.UsePasswordResetToken(r.Context(), auth.HashEmailToken(resetPassword.Token))
+	if err != nil {
+		errData := makeChirpError("reset token is invalid or has expired")
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// whoever knew the old password may still hold a session, or have
+	// let an app in with it
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = qtx.RevokeAllOAuthGrantsForUser(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendMailInBackground sends msg without holding up the request, so response
+// times don't depend on whether an email was sent.
+func sendMailInBackground(cfg *apiConfig, msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err := cfg.mailer.Send(ctx, msg)
+		if err != nil {
+			fmt.Println("unable to send email")
+			fmt.Println(err)
+		}
+	}()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+// PersonalToken is a long lived token for scripts and bots. Token is only
+// filled in on the response that creates it.
+type PersonalToken struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Name        string     `json:"name"`
+	Token       string     `json:"token,omitempty"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+}
+
+func convertPersonalToken(dbToken database.PersonalAccessToken) PersonalToken {
+	token := PersonalToken{
+		ID:          dbToken.ID,
+		CreatedAt:   dbToken.CreatedAt,
+		Name:        dbToken.Name,
+		TokenPrefix: dbToken.TokenPrefix,
+		Scopes:      auth.SplitScopes(dbToken.Scopes),
+	}
+	if dbToken.ExpiresAt.Valid {
+		token.ExpiresAt = &dbToken.ExpiresAt.Time
+	}
+	if dbToken.LastUsedAt.Valid {
+		token.LastUsedAt = &dbToken.LastUsedAt.Time
+	}
+	return token
+}
+
+func handleCreatePersonalToken(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type PersonalTokenRequest struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var tokenRequest PersonalTokenRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&tokenRequest)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(tokenRequest.Name)
+	if name == "" {
+		errData := makeChirpError("token name is required")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if len(tokenRequest.Scopes) == 0 {
+		errData := makeChirpError("at least one scope is required")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	for _, scope := range tokenRequest.Scopes {
+		if !auth.ValidScope(scope) {
+			errData := makeChirpError("unknown scope " + scope)
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+	}
+	// a token can't be given more than the request creating it has, so a
+	// narrow token can't be used to mint a broader one
+	if !principal.HasScopes(tokenRequest.Scopes...) {
+		errData := makeChirpError("cannot grant scopes you do not have")
+		makeJsonResponse(w, errData, http.StatusForbidden)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if tokenRequest.ExpiresAt != nil {
+		if tokenRequest.ExpiresAt.Before(time.Now()) {
+			errData := makeChirpError("expiry must be in the future")
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+		expiresAt = sql.NullTime{Time: tokenRequest.ExpiresAt.UTC(), Valid: true}
+	}
+
+	token, err := auth.MakePersonalToken()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	dbToken, err := cfg.db.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:      principal.UserID,
+		Name:        name,
+		TokenHash:   auth.HashPersonalToken(token),
+		TokenPrefix: auth.PersonalTokenDisplay(token),
+		Scopes:      auth.JoinScopes(tokenRequest.Scopes),
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// only the hash is kept, so this is the one chance to see the token
+	personalToken := convertPersonalToken(dbToken)
+	personalToken.Token = token
+
+	data, err := json.Marshal(personalToken)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+func handleGetPersonalTokens(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	dbTokens, err := cfg.db.GetPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	tokens := []PersonalToken{}
+	for _, dbToken := range dbTokens {
+		tokens = append(tokens, convertPersonalToken(dbToken))
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleRevokePersonalToken(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	revoked, err := cfg.db.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if revoked == 0 {
+		errData := makeChirpError("token not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
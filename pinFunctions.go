@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	maxPinnedChirps    = 3
+	maxPinnedChirpsRed = 10
+)
+
+// chirpy red members get to pin more
+func pinLimitFor(user database.User) int {
+	if user.IsChirpyRed {
+		return maxPinnedChirpsRed
+	}
+	return maxPinnedChirps
+}
+
+func handlePinChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// the user stays locked until commit so two pins at once can't both
+	// squeeze in under the limit
+	user, err := qtx.GetUserByIdForUpdate(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirp, err := qtx.GetChirpById(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if chirp.UserID != user.ID {
+		errData := makeChirpError("user is not the author")
+		makeJsonResponse(w, errData, http.StatusForbidden)
+		return
+	}
+	if chirp.ReshareKind.String == reshareRechirp {
+		errData := makeChirpError("a rechirp can't be pinned, pin the original")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	// pinning a chirp twice is not an error, it just stays pinned
+	added, err := qtx.PinChirp(r.Context(), database.PinChirpParams{
+		ChirpID: chirp.ID,
+		UserID:  user.ID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	pinned, err := qtx.CountPinnedChirps(r.Context(), user.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	limit := pinLimitFor(user)
+	if added > 0 && pinned > int64(limit) {
+		errData := makeChirpError(fmt.Sprintf("can't pin more than %d chirps", limit))
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirps := []Chirp{convertChirp(chirp)}
+	decorateChirps(r, cfg, chirps)
+	chirps[0].Pinned = true
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if added > 0 {
+		status = http.StatusCreated
+	}
+	makeJsonResponse(w, data, status)
+}
+
+func handleUnpinChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	unpinned, err := cfg.db.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if unpinned == 0 {
+		errData := makeChirpError("chirp is not pinned")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
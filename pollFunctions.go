@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/polls"
+)
+
+// PollRequest is the poll sent along with a new chirp.
+type PollRequest struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+func (poll *PollRequest) validate() error {
+	return polls.Validate(poll.Options, poll.ClosesAt, time.Now())
+}
+
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, poll PollRequest) error {
+	err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: poll.ClosesAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	for position, label := range poll.Options {
+		err = qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(position),
+			Label:    label,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Poll is the poll on a chirp. The counts are left out until the user
+// asking has voted or the poll has closed, so nobody votes with the crowd.
+type Poll struct {
+	ClosesAt      time.Time    `json:"closes_at"`
+	Closed        bool         `json:"closed"`
+	Options       []PollOption `json:"options"`
+	TotalVotes    *int         `json:"total_votes"`
+	VotedOptionID *uuid.UUID   `json:"voted_option_id"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label"`
+	Votes *int      `json:"votes"`
+}
+
+// attachPolls fills in the polls of every chirp that has one, as the
+// viewer should see them.
+func attachPolls(r *http.Request, cfg *apiConfig, viewer uuid.NullUUID, chirps []*Chirp) {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	dbPolls, err := cfg.db.GetPolls(r.Context(), ids)
+	if err == nil && len(dbPolls) == 0 {
+		return
+	}
+	var dbOptions []database.PollOption
+	if err == nil {
+		dbOptions, err = cfg.db.GetPollOptions(r.Context(), ids)
+	}
+	var dbVotes []database.PollVote
+	if err == nil && viewer.Valid {
+		dbVotes, err = cfg.db.GetUserPollVotes(r.Context(), database.GetUserPollVotesParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		})
+	}
+	if err != nil {
+		fmt.Println("unable to look up polls")
+		fmt.Println(err)
+		return
+	}
+
+	polls := map[uuid.UUID]*Poll{}
+	for _, dbPoll := range dbPolls {
+		polls[dbPoll.ChirpID] = &Poll{
+			ClosesAt: dbPoll.ClosesAt,
+			Closed:   !time.Now().Before(dbPoll.ClosesAt),
+			Options:  []PollOption{},
+		}
+	}
+	for _, dbVote := range dbVotes {
+		if poll, ok := polls[dbVote.ChirpID]; ok {
+			poll.VotedOptionID = &dbVote.OptionID
+		}
+	}
+	for _, dbOption := range dbOptions {
+		poll, ok := polls[dbOption.ChirpID]
+		if !ok {
+			continue
+		}
+		option := PollOption{ID: dbOption.ID, Label: dbOption.Label}
+		if poll.Closed || poll.VotedOptionID != nil {
+			votes := int(dbOption.VoteCount)
+			option.Votes = &votes
+			if poll.TotalVotes == nil {
+				poll.TotalVotes = new(int)
+			}
+			*poll.TotalVotes += votes
+		}
+		poll.Options = append(poll.Options, option)
+	}
+
+	for _, chirp := range chirps {
+		chirp.Poll = polls[chirp.ID]
+	}
+}
+
+func handleVoteInPoll(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type VoteRequest struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	var vote VoteRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&vote)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		errData := makeChirpError("poll not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	dbPolls, err := cfg.db.GetPolls(r.Context(), []uuid.UUID{chirpID})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if len(dbPolls) == 0 {
+		errData := makeChirpError("poll not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	voted, err := cfg.db.VoteInPoll(r.Context(), database.VoteInPollParams{
+		UserID:   userID,
+		OptionID: vote.OptionID,
+		ChirpID:  chirpID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	// the insert checks everything at once, so work out which one failed
+	if voted == 0 {
+		dbVotes, err := cfg.db.GetUserPollVotes(r.Context(), database.GetUserPollVotesParams{
+			UserID:   userID,
+			ChirpIds: []uuid.UUID{chirpID},
+		})
+		if err != nil {
+			quickChirpError(w, err.Error())
+			return
+		}
+		switch {
+		case len(dbVotes) > 0:
+			errData := makeChirpError("already voted in this poll")
+			makeJsonResponse(w, errData, http.StatusConflict)
+		case !time.Now().Before(dbPolls[0].ClosesAt):
+			errData := makeChirpError("poll has closed")
+			makeJsonResponse(w, errData, http.StatusForbidden)
+		default:
+			errData := makeChirpError("option is not in this poll")
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+		}
+		return
+	}
+
+	chirp, err = cfg.db.GetChirpById(r.Context(), chirpID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	chirps := []Chirp{convertChirp(chirp)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	reshareRechirp = "rechirp"
+	reshareQuote   = "quote"
+)
+
+var errReshareNotFound = errors.New("the chirp being reshared does not exist")
+
+// ChirpReshare is the chirp a rechirp or quote points at. Chirp is left
+// out and Unavailable set once that chirp has been deleted.
+type ChirpReshare struct {
+	Kind        string     `json:"kind"`
+	ChirpID     *uuid.UUID `json:"chirp_id"`
+	Chirp       *Chirp     `json:"chirp"`
+	Unavailable bool       `json:"unavailable"`
+}
+
+// reshareTarget finds the chirp to reshare. Resharing a plain rechirp
+// reshares the chirp it points at.
+func reshareTarget(ctx context.Context, cfg *apiConfig, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.db.GetChirpById(ctx, chirpID)
+	if err == nil && chirp.ReshareKind.String == reshareRechirp {
+		if !chirp.ReshareOf.Valid {
+			return database.Chirp{}, errReshareNotFound
+		}
+		chirp, err = cfg.db.GetChirpById(ctx, chirp.ReshareOf.UUID)
+	}
+	if errors.Is(err, sql.ErrNoRows) || chirp.DeletedAt.Valid {
+		return database.Chirp{}, errReshareNotFound
+	}
+	return chirp, err
+}
+
+// attachReshares fills in the chirps that rechirps and quotes point at,
+// in one query for the whole page.
+func attachReshares(r *http.Request, cfg *apiConfig, chirps []Chirp) {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.Reshare != nil && chirp.Reshare.ChirpID != nil {
+			ids = append(ids, *chirp.Reshare.ChirpID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	dbChirps, err := cfg.db.GetChirpsByIds(r.Context(), ids)
+	if err != nil {
+		fmt.Println("unable to look up reshared chirps")
+		fmt.Println(err)
+	}
+	reshared := map[uuid.UUID]database.Chirp{}
+	for _, dbChirp := range dbChirps {
+		reshared[dbChirp.ID] = dbChirp
+	}
+
+	for i := range chirps {
+		reshare := chirps[i].Reshare
+		if reshare == nil || reshare.ChirpID == nil {
+			continue
+		}
+		dbChirp, ok := reshared[*reshare.ChirpID]
+		if !ok || dbChirp.DeletedAt.Valid {
+			reshare.Unavailable = true
+			continue
+		}
+		chirp := convertChirp(dbChirp)
+		reshare.Chirp = &chirp
+	}
+}
+
+func handleRechirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+	if !checkCanPost(w, r, cfg, userID) {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	target, err := reshareTarget(r.Context(), cfg, chirpID)
+	if errors.Is(err, errReshareNotFound) {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	dbChirp, err := cfg.db.AddChirp(r.Context(), database.AddChirpParams{
+		UserID:      userID,
+		ReshareOf:   uuid.NullUUID{UUID: target.ID, Valid: true},
+		ReshareKind: sql.NullString{String: reshareRechirp, Valid: true},
+	})
+	// the unique index is what stops two rechirps racing each other in
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		errData := makeChirpError("chirp already rechirped")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirps := []Chirp{convertChirp(dbChirp)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusCreated)
+}
+
+func handleUndoRechirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	removed, err := cfg.db.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:    userID,
+		ReshareOf: uuid.NullUUID{UUID: chirpID, Valid: true},
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if removed == 0 {
+		errData := makeChirpError("rechirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
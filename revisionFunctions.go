@@ -0,0 +1,190 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	defaultChirpEditWindow    = time.Minute * 5
+	defaultChirpEditWindowRed = time.Hour
+)
+
+// ChirpRevision is a body a chirp had before it was edited.
+type ChirpRevision struct {
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) editWindowFor(user database.User) time.Duration {
+	if user.IsChirpyRed {
+		return cfg.chirpEditWindowRed
+	}
+	return cfg.chirpEditWindow
+}
+
+func handleEditChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	var edit Chirp
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&edit)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	body, err := validateChirp(edit.Body)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// the row stays locked until commit so two edits can't both save the
+	// same old body as their revision
+	chirp, err := qtx.GetChirpByIdForUpdate(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if chirp.UserID != user.ID {
+		errData := makeChirpError("user is not the author")
+		makeJsonResponse(w, errData, http.StatusForbidden)
+		return
+	}
+	if chirp.ReshareKind.String == reshareRechirp {
+		errData := makeChirpError("a rechirp has no body to edit")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if time.Since(chirp.CreatedAt) > cfg.editWindowFor(user) {
+		errData := makeChirpError("the edit window for this chirp has closed")
+		makeJsonResponse(w, errData, http.StatusForbidden)
+		return
+	}
+
+	// the current body was posted when the chirp was made or last edited
+	postedAt := chirp.CreatedAt
+	if chirp.EditedAt.Valid {
+		postedAt = chirp.EditedAt.Time
+	}
+	err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+		CreatedAt: postedAt,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	edited, err := qtx.EditChirp(r.Context(), database.EditChirpParams{
+		ID:   chirp.ID,
+		Body: body,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	err = indexChirpText(r.Context(), qtx, edited.ID, edited.Body)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirps := []Chirp{convertChirp(edited)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleGetChirpRevisions(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		errData := makeChirpError("chirp not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	dbRevisions, err := cfg.db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	revisions := []ChirpRevision{}
+	for _, dbRevision := range dbRevisions {
+		revisions = append(revisions, ChirpRevision{
+			Body:       dbRevision.Body,
+			CreatedAt:  dbRevision.CreatedAt,
+			ReplacedAt: dbRevision.ReplacedAt,
+		})
+	}
+
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/chirptext"
+	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/paging"
+)
+
+const maxSearchLength = 200
+
+// handleSearchChirps finds chirps whose body matches q. author_id, since
+// and until narrow it down, and it pages like GET /api/chirps.
+func handleSearchChirps(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if len(q) > maxSearchLength {
+		errData := makeChirpError("search is too long")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	tsQuery := chirptext.SearchQuery(q)
+	if tsQuery == "" {
+		errData := makeChirpError("q must have at least one word to search for")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	args := database.SearchChirpsParams{Query: tsQuery}
+	if authorIdString := query.Get("author_id"); authorIdString != "" {
+		parsed, err := uuid.Parse(authorIdString)
+		if err != nil {
+			errData := makeChirpError(err.Error())
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+		args.AuthorID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+	for name, field := range map[string]*sql.NullTime{"since": &args.Since, "until": &args.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errData := makeChirpError(name + " must be an RFC 3339 time")
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+		*field = sql.NullTime{Time: parsed.UTC(), Valid: true}
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+	if page.afterCreatedAt.Valid && !page.afterRank.Valid {
+		errData := makeChirpError("invalid cursor")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	args.AfterRank = page.afterRank
+	args.AfterCreatedAt = page.afterCreatedAt
+	args.AfterID = page.afterID
+	args.RowLimit = int32(page.limit + 1)
+
+	rows, err := cfg.db.SearchChirps(r.Context(), args)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	results := ChirpPage{Chirps: []Chirp{}}
+	if len(rows) > page.limit {
+		rows = rows[:page.limit]
+		last := rows[len(rows)-1]
+		results.NextCursor = paging.Cursor{CreatedAt: last.Chirp.CreatedAt, ID: last.Chirp.ID, Rank: &last.Rank}.Encode()
+	}
+	for _, row := range rows {
+		results.Chirps = append(results.Chirps, convertChirp(row.Chirp))
+	}
+	decorateChirps(r, cfg, results.Chirps)
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+// Session is one login, followed through every refresh token rotation
+// since. Its ID is the refresh token family.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+func convertSession(dbSession database.GetActiveSessionsRow) Session {
+	session := Session{
+		ID:        dbSession.FamilyID,
+		CreatedAt: dbSession.StartedAt,
+		UserAgent: dbSession.UserAgent.String,
+		IPAddress: dbSession.IpAddress.String,
+		ExpiresAt: dbSession.ExpiredAt,
+	}
+	if dbSession.LastUsedAt.Valid {
+		session.LastUsedAt = &dbSession.LastUsedAt.Time
+	}
+	return session
+}
+
+func handleGetSessions(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	dbSessions, err := cfg.db.GetActiveSessions(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	sessions := []Session{}
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, convertSession(dbSession))
+	}
+
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleDeleteSession(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	// sessions of other users look the same as ones that don't exist
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	if revoked == 0 {
+		errData := makeChirpError("session not found")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLogoutAll revokes every refresh token the user has, their own and
+// the ones given to apps. Access tokens that were already handed out keep
+// working until they expire.
+func handleLogoutAll(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = qtx.RevokeAllOAuthGrantsForUser(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
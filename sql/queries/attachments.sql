@@ -0,0 +1,34 @@
+-- name: CreateAttachment :one
+insert into attachments (id, created_at, user_id, content_type, blob_key, thumbnail_key, width, height, size_bytes)
+values ($1, now(), $2, $3, $4, $5, $6, $7, $8)
+returning *;
+
+-- name: AttachToChirp :execrows
+-- Only the user's own attachments that aren't on a chirp yet can be used.
+update attachments
+set chirp_id = sqlc.arg(chirp_id), position = sqlc.arg(position)
+where id = sqlc.arg(id) and user_id = sqlc.arg(user_id) and chirp_id is null;
+
+-- name: GetChirpAttachments :many
+select * from attachments
+where chirp_id = any(sqlc.arg(chirp_ids)::uuid[])
+order by chirp_id, position;
+
+-- name: DeleteChirpAttachments :many
+-- Returns the deleted rows so their blobs can be removed too.
+delete from attachments
+where chirp_id = $1
+returning *;
+
+-- name: DeleteUnattachedAttachments :many
+-- Uploads that were never put on a chirp and are older than the cutoff.
+-- Returns the deleted rows so their blobs can be removed too.
+delete from attachments
+where id in (
+    select id from attachments
+    where chirp_id is null and created_at < sqlc.arg(cutoff)
+    order by created_at
+    limit sqlc.arg(row_limit)
+    for update skip locked
+)
+returning *;
@@ -0,0 +1,24 @@
+-- name: AddBookmark :execrows
+insert into bookmarks (user_id, chirp_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
+
+-- name: DeleteBookmark :execrows
+delete from bookmarks
+where user_id = $1 and chirp_id = $2;
+
+-- name: GetBookmarkedChirpIDs :many
+-- Which of the given chirps the user has bookmarked.
+select chirp_id from bookmarks
+where user_id = sqlc.arg(user_id) and chirp_id = any(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetUserBookmarks :many
+select sqlc.embed(chirps), bookmarks.created_at as bookmarked_at
+from bookmarks
+join chirps on chirps.id = bookmarks.chirp_id
+where bookmarks.user_id = sqlc.arg(user_id)
+    and chirps.deleted_at is null
+    and (sqlc.narg(before_bookmarked_at)::timestamp is null
+        or (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(before_bookmarked_at), sqlc.narg(before_chirp_id)::uuid))
+order by bookmarks.created_at desc, bookmarks.chirp_id desc
+limit sqlc.arg(row_limit);
@@ -0,0 +1,12 @@
+-- name: CreateChirpRevision :exec
+insert into chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+values (gen_random_uuid(), $1, $2, $3, now());
+
+-- name: GetChirpRevisions :many
+select * from chirp_revisions
+where chirp_id = $1
+order by created_at asc;
+
+-- name: DeleteChirpRevisions :exec
+delete from chirp_revisions
+where chirp_id = $1;
@@ -0,0 +1,41 @@
+-- name: AddChirpHashtags :exec
+insert into chirp_hashtags (chirp_id, tag)
+select $1, unnest(sqlc.arg(tags)::text[])
+on conflict do nothing;
+
+-- name: AddChirpMentions :exec
+-- Handles that don't belong to anyone are skipped, so they stay plain text.
+insert into chirp_mentions (chirp_id, user_id)
+select $1, users.id from users
+where lower(users.handle) = any(sqlc.arg(handles)::text[])
+on conflict do nothing;
+
+-- name: DeleteChirpHashtags :exec
+delete from chirp_hashtags
+where chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+delete from chirp_mentions
+where chirp_id = $1;
+
+-- name: ListHashtagChirps :many
+-- A page of chirps with the tag newest first, starting before the
+-- (created_at, id) of the last chirp on the previous page.
+select * from chirps
+where id in (select chirp_id from chirp_hashtags where tag = sqlc.arg(tag))
+    and deleted_at is null
+    and (sqlc.narg(after_created_at)::timestamp is null
+        or (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+order by created_at desc, id desc
+limit sqlc.arg(row_limit);
+
+-- name: ListMentionChirps :many
+-- A page of chirps mentioning the user newest first, paged like
+-- ListHashtagChirps.
+select * from chirps
+where id in (select chirp_id from chirp_mentions where chirp_mentions.user_id = sqlc.arg(user_id))
+    and deleted_at is null
+    and (sqlc.narg(after_created_at)::timestamp is null
+        or (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+order by created_at desc, id desc
+limit sqlc.arg(row_limit);
@@ -1,6 +1,11 @@
 -- name: AddChirp :one
-insert into chirps (id, created_at, updated_at, body, user_id)
-values (gen_random_uuid(), now(), now(), $1, $2)
+-- A reply joins the thread of the chirp it replies to, anything else starts
+-- a thread of its own.
+insert into chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, reshare_of, reshare_kind)
+select new_id, now(), now(), $1, $2, $3,
+    coalesce((select parent.thread_id from chirps parent where parent.id = $3), new_id),
+    $4, $5
+from (select gen_random_uuid() as new_id) as ids
 returning *;
 
 -- name: RemoveChirps :exec
@@ -10,15 +15,84 @@ delete from chirps;
 delete from chirps
 where id = $1;
 
--- name: GetAllChirps :many
+-- name: ListChirpsAsc :many
+-- A page of chirps oldest first, starting after the (created_at, id) of the
+-- last chirp on the previous page. A listing of one author leaves out their
+-- pinned chirps, which are shown first on their own.
 select * from chirps
-order by created_at asc;
+where deleted_at is null
+    and not (reshare_kind = 'rechirp' and reshare_of is null)
+    and (sqlc.narg(author_id)::uuid is null or user_id = sqlc.narg(author_id))
+    and (sqlc.narg(author_id)::uuid is null
+        or not exists (select 1 from pinned_chirps where pinned_chirps.chirp_id = chirps.id))
+    and (sqlc.narg(after_created_at)::timestamp is null
+        or (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+order by created_at asc, id asc
+limit sqlc.arg(row_limit);
 
--- name: GetAllChirpsByAuthor :many
+-- name: ListChirpsDesc :many
+-- A page of chirps newest first, starting before the (created_at, id) of
+-- the last chirp on the previous page. Pinned chirps are left out like in
+-- ListChirpsAsc.
 select * from chirps
-where user_id = $1
-order by created_at asc;
+where deleted_at is null
+    and not (reshare_kind = 'rechirp' and reshare_of is null)
+    and (sqlc.narg(author_id)::uuid is null or user_id = sqlc.narg(author_id))
+    and (sqlc.narg(author_id)::uuid is null
+        or not exists (select 1 from pinned_chirps where pinned_chirps.chirp_id = chirps.id))
+    and (sqlc.narg(after_created_at)::timestamp is null
+        or (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+order by created_at desc, id desc
+limit sqlc.arg(row_limit);
 
 -- name: GetChirpById :one
 select * from chirps
-where id = $1;
\ No newline at end of file
+where id = $1;
+
+-- name: GetChirpsByIds :many
+select * from chirps
+where id = any(sqlc.arg(ids)::uuid[]);
+
+-- name: GetChirpByIdForUpdate :one
+select * from chirps
+where id = $1
+for update;
+
+-- name: EditChirp :one
+update chirps
+set body = $2, updated_at = now(), edited_at = now()
+where id = $1
+returning *;
+
+-- name: ChirpHasReplies :one
+select exists(select 1 from chirps where in_reply_to = $1);
+
+-- name: TombstoneChirp :exec
+update chirps
+set body = '', updated_at = now(), tombstoned_at = now()
+where id = $1;
+
+-- name: GetChirpThread :many
+-- Every chirp in the thread of the given chirp, depth first with replies
+-- oldest first. Chirps whose parent is gone start their own branch at
+-- depth 0.
+with recursive thread as (
+    select chirps.id, 0 as depth,
+        array[to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text] as sort_path
+    from chirps
+    where chirps.thread_id = (select c.thread_id from chirps c where c.id = $1)
+        and chirps.in_reply_to is null
+    union all
+    select reply.id, thread.depth + 1,
+        thread.sort_path || (to_char(reply.created_at, 'YYYYMMDDHH24MISSUS') || reply.id::text)
+    from chirps reply
+    join thread on reply.in_reply_to = thread.id
+)
+select sqlc.embed(chirps), thread.depth
+from thread
+join chirps on chirps.id = thread.id
+order by thread.sort_path;
+
+-- name: DeleteRechirp :execrows
+delete from chirps
+where user_id = $1 and reshare_of = $2 and reshare_kind = 'rechirp';
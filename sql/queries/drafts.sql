@@ -0,0 +1,44 @@
+-- name: CreateDraft :one
+insert into chirp_drafts (id, created_at, updated_at, user_id, body, in_reply_to, quote_of, publish_at)
+values (gen_random_uuid(), now(), now(), $1, $2, $3, $4, $5)
+returning *;
+
+-- name: GetDraft :one
+select * from chirp_drafts
+where id = $1 and user_id = $2;
+
+-- name: GetDraftForUpdate :one
+select * from chirp_drafts
+where id = $1 and user_id = $2
+for update;
+
+-- name: GetDrafts :many
+-- Scheduled drafts first, soonest first, then the rest newest first.
+select * from chirp_drafts
+where user_id = $1
+order by publish_at asc nulls last, created_at desc;
+
+-- name: UpdateDraft :one
+update chirp_drafts
+set body = $3, in_reply_to = $4, quote_of = $5, publish_at = $6, last_error = null, updated_at = now()
+where id = $1 and user_id = $2
+returning *;
+
+-- name: DeleteDraft :execrows
+delete from chirp_drafts
+where id = $1 and user_id = $2;
+
+-- name: ClaimDueDrafts :many
+-- Locks scheduled drafts whose time has come. Drafts another server is
+-- already publishing are skipped rather than waited for, so each is
+-- published exactly once.
+select * from chirp_drafts
+where publish_at <= now()
+order by publish_at
+limit $1
+for update skip locked;
+
+-- name: FailDraft :exec
+update chirp_drafts
+set publish_at = null, last_error = $2, updated_at = now()
+where id = $1;
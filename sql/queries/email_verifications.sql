@@ -0,0 +1,14 @@
+-- name: CreateEmailVerificationToken :exec
+insert into email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+values ($1, $2, $3, now(), $4);
+
+-- name: InvalidateEmailVerificationTokens :exec
+update email_verification_tokens
+set used_at = now()
+where user_id = $1 and used_at is null;
+
+-- name: UseEmailVerificationToken :one
+update email_verification_tokens
+set used_at = now()
+where token_hash = $1 and used_at is null and expires_at > now()
+returning user_id, email;
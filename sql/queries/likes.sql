@@ -0,0 +1,24 @@
+-- name: LikeChirp :execrows
+insert into likes (user_id, chirp_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
+
+-- name: UnlikeChirp :execrows
+delete from likes
+where user_id = $1 and chirp_id = $2;
+
+-- name: GetLikedChirpIDs :many
+-- Which of the given chirps the user has liked.
+select chirp_id from likes
+where user_id = sqlc.arg(user_id) and chirp_id = any(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetUserLikes :many
+select sqlc.embed(chirps), likes.created_at as liked_at
+from likes
+join chirps on chirps.id = likes.chirp_id
+where likes.user_id = sqlc.arg(user_id)
+    and chirps.deleted_at is null
+    and (sqlc.narg(before_liked_at)::timestamp is null
+        or (likes.created_at, likes.chirp_id) < (sqlc.narg(before_liked_at), sqlc.narg(before_chirp_id)::uuid))
+order by likes.created_at desc, likes.chirp_id desc
+limit sqlc.arg(row_limit);
@@ -0,0 +1,24 @@
+-- name: GetLoginThrottle :one
+select * from login_throttles
+where throttle_key = $1;
+
+-- name: RecordLoginFailure :one
+-- the count starts over once a key has been quiet for a day
+insert into login_throttles (throttle_key, failures, last_failure_at)
+values ($1, 1, now())
+on conflict (throttle_key) do update
+set failures = case
+        when login_throttles.last_failure_at < now() - interval '1 day' then 1
+        else login_throttles.failures + 1
+    end,
+    last_failure_at = now()
+returning *;
+
+-- name: LockLoginThrottle :exec
+update login_throttles
+set locked_until = $2
+where throttle_key = $1;
+
+-- name: ClearLoginThrottle :exec
+delete from login_throttles
+where throttle_key = $1;
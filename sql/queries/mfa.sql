@@ -0,0 +1,37 @@
+-- name: SetTOTPSecret :exec
+update users
+set totp_secret = $2, totp_enabled = false, totp_last_step = null, updated_at = now()
+where id = $1;
+
+-- name: EnableTOTP :exec
+update users
+set totp_enabled = true, updated_at = now()
+where id = $1;
+
+-- name: UseTOTPStep :execrows
+-- Records the time step of an accepted totp code. Nothing changes if a code
+-- from that step or a later one was already accepted, so each code only
+-- works once.
+update users
+set totp_last_step = sqlc.arg(step)::bigint
+where id = sqlc.arg(id)
+    and (totp_last_step is null or totp_last_step < sqlc.arg(step)::bigint);
+
+-- name: DisableTOTP :exec
+update users
+set totp_secret = null, totp_enabled = false, totp_last_step = null, updated_at = now()
+where id = $1;
+
+-- name: CreateRecoveryCode :exec
+insert into recovery_codes (id, user_id, code_hash, created_at)
+values (gen_random_uuid(), $1, $2, now());
+
+-- name: DeleteRecoveryCodes :exec
+delete from recovery_codes
+where user_id = $1;
+
+-- name: UseRecoveryCode :one
+update recovery_codes
+set used_at = now()
+where user_id = $1 and code_hash = $2 and used_at is null
+returning id;
@@ -0,0 +1,87 @@
+-- name: CreateOAuthClient :one
+insert into oauth_clients (id, created_at, updated_at, user_id, name, secret_hash, redirect_uris)
+values (gen_random_uuid(), now(), now(), $1, $2, $3, $4)
+returning *;
+
+-- name: GetOAuthClient :one
+select * from oauth_clients
+where id = $1;
+
+-- name: GetOAuthClientsForUser :many
+select * from oauth_clients
+where user_id = $1
+order by created_at desc;
+
+-- name: DeleteOAuthClient :execrows
+delete from oauth_clients
+where id = $1 and user_id = $2;
+
+-- name: CreateAuthorizationCode :exec
+insert into oauth_authorization_codes (
+    code_hash, client_id, user_id, redirect_uri, scopes, code_challenge,
+    created_at, expires_at
+)
+values ($1, $2, $3, $4, $5, $6, now(), $7);
+
+-- name: UseAuthorizationCode :one
+update oauth_authorization_codes
+set used_at = now()
+where code_hash = $1 and used_at is null and expires_at > now()
+returning *;
+
+-- name: CreateOAuthRefreshToken :exec
+insert into oauth_refresh_tokens (token_hash, client_id, user_id, scopes, created_at, expires_at)
+values ($1, $2, $3, $4, now(), $5);
+
+-- name: RotateOAuthRefreshToken :one
+update oauth_refresh_tokens
+set revoked_at = now()
+where token_hash = $1 and client_id = $2 and revoked_at is null and expires_at > now()
+returning *;
+
+-- name: GetOAuthRefreshToken :one
+select * from oauth_refresh_tokens
+where token_hash = $1 and client_id = $2;
+
+-- name: RevokeOAuthRefreshToken :exec
+update oauth_refresh_tokens
+set revoked_at = now()
+where token_hash = $1 and client_id = $2 and revoked_at is null;
+
+-- name: GetOAuthGrantsForUser :many
+-- Apps that can still act for the user, one row per app.
+select
+    oauth_clients.id as client_id,
+    oauth_clients.name as client_name,
+    string_agg(distinct oauth_refresh_tokens.scopes, ' ')::text as scopes,
+    min(oauth_refresh_tokens.created_at)::timestamp as granted_at,
+    max(oauth_refresh_tokens.created_at)::timestamp as last_used_at
+from oauth_refresh_tokens
+join oauth_clients on oauth_clients.id = oauth_refresh_tokens.client_id
+where oauth_refresh_tokens.user_id = $1
+    and oauth_refresh_tokens.revoked_at is null
+    and oauth_refresh_tokens.expires_at > now()
+group by oauth_clients.id, oauth_clients.name
+order by last_used_at desc;
+
+-- name: RevokeOAuthGrant :execrows
+-- Takes back everything one app was given, including codes it hasn't
+-- exchanged yet.
+with codes as (
+    update oauth_authorization_codes
+    set used_at = now()
+    where user_id = $1 and client_id = $2 and used_at is null
+)
+update oauth_refresh_tokens
+set revoked_at = now()
+where user_id = $1 and client_id = $2 and revoked_at is null;
+
+-- name: RevokeAllOAuthGrantsForUser :exec
+with codes as (
+    update oauth_authorization_codes
+    set used_at = now()
+    where user_id = $1 and used_at is null
+)
+update oauth_refresh_tokens
+set revoked_at = now()
+where user_id = $1 and revoked_at is null;
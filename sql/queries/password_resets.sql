@@ -0,0 +1,24 @@
+-- name: CreatePasswordResetToken :exec
+insert into password_reset_tokens (token_hash, user_id, created_at, expires_at)
+values ($1, $2, now(), $3);
+
+-- name: HasFreshPasswordResetToken :one
+-- Whether the user was sent a link that still works since sent_since.
+select exists (
+    select 1 from password_reset_tokens
+    where user_id = sqlc.arg(user_id)
+        and used_at is null
+        and expires_at > now()
+        and created_at > sqlc.arg(sent_since)::timestamp
+);
+
+-- name: InvalidatePasswordResetTokens :exec
+update password_reset_tokens
+set used_at = now()
+where user_id = $1 and used_at is null;
+
+-- name: UsePasswordResetToken :one
+update password_reset_tokens
+set used_at = now()
+where token_hash = $1 and used_at is null and expires_at > now()
+returning user_id;
@@ -0,0 +1,29 @@
+-- name: CreatePersonalAccessToken :one
+insert into personal_access_tokens (
+    id, created_at, updated_at, user_id, name, token_hash, token_prefix,
+    scopes, expires_at
+)
+values (gen_random_uuid(), now(), now(), $1, $2, $3, $4, $5, $6)
+returning *;
+
+-- name: GetPersonalAccessTokens :many
+select * from personal_access_tokens
+where user_id = $1 and revoked_at is null
+order by created_at desc;
+
+-- name: RevokePersonalAccessToken :execrows
+update personal_access_tokens
+set updated_at = now(), revoked_at = now()
+where id = $1 and user_id = $2 and revoked_at is null;
+
+-- name: UsePersonalAccessToken :one
+-- Finds a usable token by its hash and records that it was used. The
+-- owner's role is returned so the token's scopes can be limited by it.
+update personal_access_tokens
+set last_used_at = now()
+from users
+where personal_access_tokens.token_hash = $1
+    and personal_access_tokens.revoked_at is null
+    and (personal_access_tokens.expires_at is null or personal_access_tokens.expires_at > now())
+    and users.id = personal_access_tokens.user_id
+returning personal_access_tokens.user_id, personal_access_tokens.scopes, users.role;
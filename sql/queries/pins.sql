@@ -0,0 +1,24 @@
+-- name: PinChirp :execrows
+insert into pinned_chirps (chirp_id, user_id, created_at)
+values ($1, $2, now())
+on conflict do nothing;
+
+-- name: UnpinChirp :execrows
+delete from pinned_chirps
+where chirp_id = $1 and user_id = $2;
+
+-- name: DeletePin :exec
+delete from pinned_chirps
+where chirp_id = $1;
+
+-- name: CountPinnedChirps :one
+select count(*) from pinned_chirps
+where user_id = $1;
+
+-- name: ListPinnedChirps :many
+-- The user's pinned chirps, most recently pinned first.
+select chirps.* from pinned_chirps
+join chirps on chirps.id = pinned_chirps.chirp_id
+where pinned_chirps.user_id = $1
+    and chirps.deleted_at is null
+order by pinned_chirps.created_at desc;
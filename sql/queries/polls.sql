@@ -0,0 +1,36 @@
+-- name: CreatePoll :exec
+insert into polls (chirp_id, created_at, closes_at)
+values ($1, now(), $2);
+
+-- name: CreatePollOption :exec
+insert into poll_options (id, chirp_id, position, label)
+values (gen_random_uuid(), $1, $2, $3);
+
+-- name: DeletePoll :exec
+delete from polls
+where chirp_id = $1;
+
+-- name: GetPolls :many
+select * from polls
+where chirp_id = any(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollOptions :many
+select * from poll_options
+where chirp_id = any(sqlc.arg(chirp_ids)::uuid[])
+order by chirp_id, position;
+
+-- name: GetUserPollVotes :many
+select * from poll_votes
+where user_id = sqlc.arg(user_id) and chirp_id = any(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: VoteInPoll :execrows
+-- Nothing is inserted if the option isn't in this poll, the poll has
+-- closed or the user already voted.
+insert into poll_votes (chirp_id, user_id, option_id, created_at)
+select poll_options.chirp_id, sqlc.arg(user_id), poll_options.id, now()
+from poll_options
+join polls on polls.chirp_id = poll_options.chirp_id
+where poll_options.id = sqlc.arg(option_id)
+    and poll_options.chirp_id = sqlc.arg(chirp_id)
+    and polls.closes_at > now()
+on conflict do nothing;
@@ -1,20 +1,72 @@
 -- name: CreateRefreshToken :one
-insert into refresh_tokens (token, created_at, updated_at, user_id, expired_at)
-values ($1, now(), now(), $2, $3)
+insert into refresh_tokens (
+    token_hash, token_prefix, created_at, updated_at, user_id, expired_at,
+    family_id, parent_token_hash, last_used_at, user_agent, ip_address
+)
+values ($1, $2, now(), now(), $3, $4, $5, $6, now(), $7, $8)
 returning *;
 
 -- name: RemoveAllRefreshTokens :exec
 delete from refresh_tokens;
 
 -- name: GetAllRefreshTokens :many
-select * from refresh_tokens
+select token_prefix, created_at, updated_at, user_id, expired_at, revoked_at, family_id
+from refresh_tokens
 order by created_at desc;
 
 -- name: GetRefeshToken :one
 select * from refresh_tokens
-where token = $1;
+where token_hash = $1;
 
 -- name: RevokeRefreshToken :exec
 update refresh_tokens
 set updated_at = now(), revoked_at = now()
-where token = $1;
\ No newline at end of file
+where token_hash = $1;
+
+-- name: RotateRefreshToken :one
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where token_hash = $1 and revoked_at is null
+returning *;
+
+-- name: RevokeRefreshTokenFamily :exec
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where family_id = $1 and revoked_at is null;
+
+-- name: GetLegacyRefreshTokens :many
+select token_hash from refresh_tokens
+where token_prefix is null;
+
+-- name: HashLegacyRefreshToken :exec
+update refresh_tokens
+set token_hash = sqlc.arg(new_token_hash), token_prefix = sqlc.arg(token_prefix)
+where token_hash = sqlc.arg(old_token_hash);
+
+-- name: HashLegacyParentToken :exec
+update refresh_tokens
+set parent_token_hash = sqlc.arg(new_token_hash)
+where parent_token_hash = sqlc.arg(old_token_hash);
+
+-- name: RevokeAllRefreshTokensForUser :exec
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where user_id = $1 and revoked_at is null;
+
+-- name: GetActiveSessions :many
+-- a session is a token family, its one unrevoked token is the latest use
+select
+    rt.family_id,
+    (select min(f.created_at) from refresh_tokens f where f.family_id = rt.family_id)::timestamp as started_at,
+    rt.last_used_at,
+    rt.user_agent,
+    rt.ip_address,
+    rt.expired_at
+from refresh_tokens rt
+where rt.user_id = $1 and rt.revoked_at is null and rt.expired_at > now()
+order by rt.last_used_at desc;
+
+-- name: RevokeSession :execrows
+update refresh_tokens
+set updated_at = now(), revoked_at = now()
+where family_id = $1 and user_id = $2 and revoked_at is null;
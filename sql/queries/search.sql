@@ -0,0 +1,20 @@
+-- name: SearchChirps :many
+-- Chirps matching a to_tsquery, best match first and newest first among
+-- equal matches, starting after the (rank, created_at, id) of the last
+-- chirp on the previous page.
+select sqlc.embed(chirps), results.rank
+from (
+    select chirps.id, chirps.created_at,
+        ts_rank(to_tsvector('english', chirps.body), to_tsquery('english', sqlc.arg(query))) as rank
+    from chirps
+    where to_tsvector('english', chirps.body) @@ to_tsquery('english', sqlc.arg(query))
+        and chirps.deleted_at is null
+        and (sqlc.narg(author_id)::uuid is null or chirps.user_id = sqlc.narg(author_id))
+        and (sqlc.narg(since)::timestamp is null or chirps.created_at >= sqlc.narg(since))
+        and (sqlc.narg(until)::timestamp is null or chirps.created_at < sqlc.narg(until))
+) as results
+join chirps on chirps.id = results.id
+where sqlc.narg(after_rank)::real is null
+    or (results.rank, results.created_at, results.id) < (sqlc.narg(after_rank), sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+order by results.rank desc, results.created_at desc, results.id desc
+limit sqlc.arg(row_limit);
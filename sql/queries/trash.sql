@@ -0,0 +1,49 @@
+-- name: SoftDeleteChirp :one
+update chirps
+set deleted_at = now(), deleted_by = $2
+where id = $1
+returning deleted_at;
+
+-- name: SoftDeleteRechirpsOf :exec
+-- A plain rechirp has nothing of its own to show, so it is deleted with the
+-- chirp it reshared, at the same moment so RestoreRechirpsOf can find it.
+-- Quotes are kept.
+update chirps
+set deleted_at = $2, deleted_by = $3
+where reshare_of = $1 and reshare_kind = 'rechirp' and deleted_at is null;
+
+-- name: RestoreChirp :one
+update chirps
+set deleted_at = null, deleted_by = null
+where id = $1
+returning *;
+
+-- name: RestoreRechirpsOf :exec
+update chirps
+set deleted_at = null, deleted_by = null
+where reshare_of = $1 and reshare_kind = 'rechirp' and deleted_at = $2;
+
+-- name: ListTrash :many
+-- A page of the chirps the user deleted themselves and can still restore,
+-- most recently deleted first, starting before the (deleted_at, id) of the
+-- last chirp on the previous page.
+select * from chirps
+where user_id = sqlc.arg(user_id)
+    and deleted_by = user_id
+    and deleted_at > sqlc.arg(deleted_since)::timestamp
+    and tombstoned_at is null
+    and reshare_kind is distinct from 'rechirp'
+    and (sqlc.narg(before_deleted_at)::timestamp is null
+        or (deleted_at, id) < (sqlc.narg(before_deleted_at), sqlc.narg(before_id)::uuid))
+order by deleted_at desc, id desc
+limit sqlc.arg(row_limit);
+
+-- name: ClaimPurgeableChirps :many
+-- Locks chirps deleted before the cutoff that haven't been purged yet,
+-- skipping any another server is already purging.
+select * from chirps
+where deleted_at < sqlc.arg(cutoff)::timestamp
+    and tombstoned_at is null
+order by deleted_at
+limit sqlc.arg(row_limit)
+for update skip locked;
@@ -13,7 +13,10 @@ where email = $1;
 
 -- name: UpdateUser :one
 update users
-set hashed_password = $2, email = $3, updated_at = now()
+set hashed_password = $2,
+    email = $3,
+    email_verified_at = case when email = $3 then email_verified_at else null end,
+    updated_at = now()
 where id = $1
 returning *;
 
@@ -27,4 +30,36 @@ returning *;
 update users 
 set is_chirpy_red = false
 where id = $1
-returning *;
\ No newline at end of file
+returning *;
+
+-- name: UpdateUserPassword :exec
+update users
+set hashed_password = $2, updated_at = now()
+where id = $1;
+
+-- name: GetUserById :one
+select * from users
+where id = $1;
+
+-- name: GetUserByIdForUpdate :one
+select * from users
+where id = $1
+for update;
+
+-- name: VerifyUserEmail :one
+update users
+set email_verified_at = now(), updated_at = now()
+where id = $1 and email = $2
+returning *;
+
+-- name: UpdateUserRole :one
+update users
+set role = $2, updated_at = now()
+where id = $1
+returning *;
+
+-- name: UpdateUserHandle :one
+update users
+set handle = $2, updated_at = now()
+where id = $1
+returning *;
@@ -0,0 +1,9 @@
+-- +goose Up
+alter table refresh_tokens add column family_id uuid not null default gen_random_uuid();
+alter table refresh_tokens add column parent_token text;
+create index refresh_tokens_family_id_idx on refresh_tokens(family_id);
+
+-- +goose Down
+drop index refresh_tokens_family_id_idx;
+alter table refresh_tokens drop column parent_token;
+alter table refresh_tokens drop column family_id;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- existing rows keep their plaintext value in token_hash with a null
+-- token_prefix until the server hashes them on startup
+alter table refresh_tokens rename column token to token_hash;
+alter table refresh_tokens rename column parent_token to parent_token_hash;
+alter table refresh_tokens add column token_prefix text;
+
+-- +goose Down
+alter table refresh_tokens drop column token_prefix;
+alter table refresh_tokens rename column parent_token_hash to parent_token;
+alter table refresh_tokens rename column token_hash to token;
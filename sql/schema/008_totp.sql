@@ -0,0 +1,21 @@
+-- +goose Up
+alter table users add column totp_secret text;
+alter table users add column totp_enabled boolean not null default false;
+
+create table recovery_codes (
+    id uuid primary key,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    code_hash text not null,
+    created_at timestamp not null,
+    used_at timestamp
+);
+create index recovery_codes_user_id_idx on recovery_codes(user_id);
+
+-- +goose Down
+drop table recovery_codes;
+alter table users drop column totp_enabled;
+alter table users drop column totp_secret;
@@ -0,0 +1,15 @@
+-- +goose Up
+create table password_reset_tokens (
+    token_hash text primary key,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    created_at timestamp not null,
+    expires_at timestamp not null,
+    used_at timestamp
+);
+
+-- +goose Down
+drop table password_reset_tokens;
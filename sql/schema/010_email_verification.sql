@@ -0,0 +1,19 @@
+-- +goose Up
+alter table users add column email_verified_at timestamp;
+
+create table email_verification_tokens (
+    token_hash text primary key,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    email text not null,
+    created_at timestamp not null,
+    expires_at timestamp not null,
+    used_at timestamp
+);
+
+-- +goose Down
+drop table email_verification_tokens;
+alter table users drop column email_verified_at;
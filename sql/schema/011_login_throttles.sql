@@ -0,0 +1,11 @@
+-- +goose Up
+-- failed login attempts, keyed by "account:<email>" or "ip:<address>"
+create table login_throttles (
+    throttle_key text primary key,
+    failures integer not null,
+    last_failure_at timestamp not null,
+    locked_until timestamp
+);
+
+-- +goose Down
+drop table login_throttles;
@@ -0,0 +1,11 @@
+-- +goose Up
+alter table refresh_tokens add column last_used_at timestamp;
+alter table refresh_tokens add column user_agent text;
+alter table refresh_tokens add column ip_address text;
+create index refresh_tokens_user_id_idx on refresh_tokens(user_id);
+
+-- +goose Down
+drop index refresh_tokens_user_id_idx;
+alter table refresh_tokens drop column ip_address;
+alter table refresh_tokens drop column user_agent;
+alter table refresh_tokens drop column last_used_at;
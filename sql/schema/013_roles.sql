@@ -0,0 +1,8 @@
+-- +goose Up
+alter table users add column role text not null default 'user';
+alter table users add constraint users_role_check
+    check (role in ('user', 'moderator', 'admin'));
+
+-- +goose Down
+alter table users drop constraint users_role_check;
+alter table users drop column role;
@@ -0,0 +1,22 @@
+-- +goose Up
+create table personal_access_tokens (
+    id uuid primary key,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    name text not null,
+    token_hash text unique not null,
+    token_prefix text not null,
+    -- space separated, the same as the scope claim in a JWT
+    scopes text not null,
+    expires_at timestamp,
+    last_used_at timestamp,
+    revoked_at timestamp
+);
+
+-- +goose Down
+drop table personal_access_tokens;
@@ -0,0 +1,58 @@
+-- +goose Up
+create table oauth_clients (
+    id uuid primary key,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    name text not null,
+    -- null for public clients, which rely on PKCE alone
+    secret_hash text,
+    redirect_uris text[] not null
+);
+
+create table oauth_authorization_codes (
+    code_hash text primary key,
+    client_id uuid not null,
+    constraint fk_client_id
+        foreign key (client_id)
+        references oauth_clients(id)
+        on delete cascade,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    redirect_uri text not null,
+    scopes text not null,
+    code_challenge text not null,
+    created_at timestamp not null,
+    expires_at timestamp not null,
+    used_at timestamp
+);
+
+create table oauth_refresh_tokens (
+    token_hash text primary key,
+    client_id uuid not null,
+    constraint fk_client_id
+        foreign key (client_id)
+        references oauth_clients(id)
+        on delete cascade,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    scopes text not null,
+    created_at timestamp not null,
+    expires_at timestamp not null,
+    revoked_at timestamp
+);
+
+-- +goose Down
+drop table oauth_refresh_tokens;
+drop table oauth_authorization_codes;
+drop table oauth_clients;
@@ -0,0 +1,22 @@
+-- +goose Up
+alter table chirps add column edited_at timestamp;
+
+-- every body a chirp had before an edit
+create table chirp_revisions (
+    id uuid primary key,
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    body text not null,
+    -- when this body was posted, and when an edit replaced it
+    created_at timestamp not null,
+    replaced_at timestamp not null
+);
+
+create index chirp_revisions_chirp_id_idx on chirp_revisions (chirp_id, created_at);
+
+-- +goose Down
+drop table chirp_revisions;
+alter table chirps drop column edited_at;
@@ -0,0 +1,24 @@
+-- +goose Up
+alter table chirps add column in_reply_to uuid;
+alter table chirps add constraint fk_in_reply_to
+    foreign key (in_reply_to)
+    references chirps(id)
+    on delete set null;
+-- the first chirp of the conversation, its own id for chirps that aren't
+-- replies
+alter table chirps add column thread_id uuid;
+update chirps set thread_id = id;
+alter table chirps alter column thread_id set not null;
+-- a deleted chirp that still has replies is kept with an empty body
+alter table chirps add column tombstoned_at timestamp;
+
+create index chirps_in_reply_to_idx on chirps (in_reply_to);
+create index chirps_thread_id_idx on chirps (thread_id);
+
+-- +goose Down
+drop index chirps_thread_id_idx;
+drop index chirps_in_reply_to_idx;
+alter table chirps drop column tombstoned_at;
+alter table chirps drop column thread_id;
+alter table chirps drop constraint fk_in_reply_to;
+alter table chirps drop column in_reply_to;
@@ -0,0 +1,45 @@
+-- +goose Up
+create table likes (
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    created_at timestamp not null,
+    primary key (user_id, chirp_id)
+);
+
+create index likes_chirp_id_idx on likes (chirp_id);
+create index likes_user_id_created_at_idx on likes (user_id, created_at, chirp_id);
+
+alter table chirps add column like_count integer not null default 0;
+
+-- the count is kept by a trigger so it stays right however a like comes
+-- and goes, including the cascade when a user is deleted
+-- +goose StatementBegin
+create function update_chirp_like_count() returns trigger as $$
+begin
+    if tg_op = 'INSERT' then
+        update chirps set like_count = like_count + 1 where id = new.chirp_id;
+    else
+        update chirps set like_count = like_count - 1 where id = old.chirp_id;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+create trigger likes_like_count
+after insert or delete on likes
+for each row execute function update_chirp_like_count();
+
+-- +goose Down
+drop trigger likes_like_count on likes;
+drop function update_chirp_like_count();
+alter table chirps drop column like_count;
+drop table likes;
@@ -0,0 +1,56 @@
+-- +goose Up
+-- a rechirp or quote points at the chirp it reshares. the reference is
+-- cleared when that chirp is deleted, and reshare_kind is kept so the
+-- reshare can still say what it was
+alter table chirps add column reshare_of uuid;
+alter table chirps add constraint fk_reshare_of
+    foreign key (reshare_of)
+    references chirps(id)
+    on delete set null;
+alter table chirps add column reshare_kind text;
+alter table chirps add constraint reshare_kind_check
+    check (reshare_kind in ('rechirp', 'quote'));
+alter table chirps add column rechirp_count integer not null default 0;
+alter table chirps add column quote_count integer not null default 0;
+
+create index chirps_reshare_of_idx on chirps (reshare_of);
+-- a user can only rechirp a chirp once
+create unique index chirps_one_rechirp_idx on chirps (user_id, reshare_of)
+    where reshare_kind = 'rechirp';
+
+-- +goose StatementBegin
+create function update_chirp_reshare_count() returns trigger as $$
+begin
+    if tg_op = 'INSERT' then
+        if new.reshare_kind = 'rechirp' then
+            update chirps set rechirp_count = rechirp_count + 1 where id = new.reshare_of;
+        elsif new.reshare_kind = 'quote' then
+            update chirps set quote_count = quote_count + 1 where id = new.reshare_of;
+        end if;
+    else
+        if old.reshare_kind = 'rechirp' then
+            update chirps set rechirp_count = rechirp_count - 1 where id = old.reshare_of;
+        elsif old.reshare_kind = 'quote' then
+            update chirps set quote_count = quote_count - 1 where id = old.reshare_of;
+        end if;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+create trigger chirps_reshare_count
+after insert or delete on chirps
+for each row execute function update_chirp_reshare_count();
+
+-- +goose Down
+drop trigger chirps_reshare_count on chirps;
+drop function update_chirp_reshare_count();
+drop index chirps_one_rechirp_idx;
+drop index chirps_reshare_of_idx;
+alter table chirps drop column quote_count;
+alter table chirps drop column rechirp_count;
+alter table chirps drop constraint reshare_kind_check;
+alter table chirps drop column reshare_kind;
+alter table chirps drop constraint fk_reshare_of;
+alter table chirps drop column reshare_of;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- the name other users @mention, unique whatever the case
+alter table users add column handle text;
+create unique index users_handle_idx on users (lower(handle));
+
+create table chirp_hashtags (
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    tag text not null,
+    primary key (chirp_id, tag)
+);
+
+create index chirp_hashtags_tag_idx on chirp_hashtags (tag);
+
+create table chirp_mentions (
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    primary key (chirp_id, user_id)
+);
+
+create index chirp_mentions_user_id_idx on chirp_mentions (user_id);
+
+-- +goose Down
+drop table chirp_mentions;
+drop table chirp_hashtags;
+drop index users_handle_idx;
+alter table users drop column handle;
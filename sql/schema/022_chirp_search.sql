@@ -0,0 +1,7 @@
+-- +goose Up
+-- searches have to use this same expression to be able to use the index
+create index chirps_body_search_idx on chirps
+    using gin (to_tsvector('english', body));
+
+-- +goose Down
+drop index chirps_body_search_idx;
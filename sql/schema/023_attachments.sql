@@ -0,0 +1,30 @@
+-- +goose Up
+-- an uploaded image, loose until a chirp is posted with it
+create table attachments (
+    id uuid primary key,
+    created_at timestamp not null,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    chirp_id uuid,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    -- where it goes among the chirp's attachments, from 0
+    position integer not null default 0,
+    content_type text not null,
+    blob_key text not null,
+    thumbnail_key text not null,
+    width integer not null,
+    height integer not null,
+    size_bytes integer not null
+);
+
+create index attachments_chirp_id_idx on attachments (chirp_id, position);
+create index attachments_user_id_idx on attachments (user_id);
+
+-- +goose Down
+drop table attachments;
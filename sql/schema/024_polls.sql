@@ -0,0 +1,71 @@
+-- +goose Up
+create table polls (
+    chirp_id uuid primary key,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    created_at timestamp not null,
+    closes_at timestamp not null
+);
+
+create table poll_options (
+    id uuid primary key,
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references polls(chirp_id)
+        on delete cascade,
+    position integer not null,
+    label text not null,
+    vote_count integer not null default 0,
+    unique (chirp_id, position)
+);
+
+-- the primary key is what makes it one vote per user
+create table poll_votes (
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references polls(chirp_id)
+        on delete cascade,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    option_id uuid not null,
+    constraint fk_option_id
+        foreign key (option_id)
+        references poll_options(id)
+        on delete cascade,
+    created_at timestamp not null,
+    primary key (chirp_id, user_id)
+);
+
+create index poll_votes_user_id_idx on poll_votes (user_id);
+
+-- kept by a trigger like chirps.like_count
+-- +goose StatementBegin
+create function update_poll_vote_count() returns trigger as $$
+begin
+    if tg_op = 'INSERT' then
+        update poll_options set vote_count = vote_count + 1 where id = new.option_id;
+    else
+        update poll_options set vote_count = vote_count - 1 where id = old.option_id;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+create trigger poll_votes_vote_count
+after insert or delete on poll_votes
+for each row execute function update_poll_vote_count();
+
+-- +goose Down
+drop trigger poll_votes_vote_count on poll_votes;
+drop function update_poll_vote_count();
+drop table poll_votes;
+drop table poll_options;
+drop table polls;
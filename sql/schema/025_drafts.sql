@@ -0,0 +1,27 @@
+-- +goose Up
+-- chirps that aren't posted yet. they live apart from chirps so nothing
+-- that reads chirps can show them by mistake. a draft with publish_at set
+-- is scheduled, and is moved into chirps once that time comes
+create table chirp_drafts (
+    id uuid primary key,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    body text not null,
+    in_reply_to uuid,
+    quote_of uuid,
+    publish_at timestamp,
+    -- why the last try to publish it failed, it goes back to being a draft
+    last_error text
+);
+
+create index chirp_drafts_user_id_idx on chirp_drafts (user_id);
+create index chirp_drafts_publish_at_idx on chirp_drafts (publish_at)
+    where publish_at is not null;
+
+-- +goose Down
+drop table chirp_drafts;
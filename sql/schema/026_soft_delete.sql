@@ -0,0 +1,25 @@
+-- +goose Up
+-- deleting a chirp only marks it, so it can be restored for a while and
+-- moderators can see what was taken down. deleted_by is who deleted it,
+-- the author or a moderator. chirps that were tombstoned before this count
+-- as deleted at the time they were tombstoned
+alter table chirps add column deleted_at timestamp;
+alter table chirps add column deleted_by uuid;
+alter table chirps add constraint fk_deleted_by
+    foreign key (deleted_by)
+    references public.users(id)
+    on delete set null;
+
+update chirps set deleted_at = tombstoned_at where tombstoned_at is not null;
+
+create index chirps_deleted_at_idx on chirps (deleted_at)
+    where deleted_at is not null;
+create index chirps_trash_idx on chirps (user_id, deleted_at)
+    where deleted_at is not null;
+
+-- +goose Down
+drop index chirps_trash_idx;
+drop index chirps_deleted_at_idx;
+alter table chirps drop constraint fk_deleted_by;
+alter table chirps drop column deleted_by;
+alter table chirps drop column deleted_at;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- bookmarks are private, only the user who made them can see them
+create table bookmarks (
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    chirp_id uuid not null,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    created_at timestamp not null,
+    primary key (user_id, chirp_id)
+);
+
+create index bookmarks_user_id_created_at_idx on bookmarks (user_id, created_at, chirp_id);
+
+-- users can only pin their own chirps, so a chirp is pinned at most once
+create table pinned_chirps (
+    chirp_id uuid primary key,
+    constraint fk_chirp_id
+        foreign key (chirp_id)
+        references chirps(id)
+        on delete cascade,
+    user_id uuid not null,
+    constraint fk_user_id
+        foreign key (user_id)
+        references public.users(id)
+        on delete cascade,
+    created_at timestamp not null
+);
+
+create index pinned_chirps_user_id_idx on pinned_chirps (user_id, created_at);
+
+-- +goose Down
+drop table pinned_chirps;
+drop table bookmarks;
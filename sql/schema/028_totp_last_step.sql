@@ -0,0 +1,8 @@
+-- +goose Up
+-- the time step of the last totp code that was accepted. a code is only
+-- taken for a later step, so one seen over someone's shoulder can't be
+-- used again while it is still valid
+alter table users add column totp_last_step bigint;
+
+-- +goose Down
+alter table users drop column totp_last_step;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- uploads that never made it onto a chirp are cleaned up by the scheduler
+create index attachments_unattached_idx on attachments (created_at) where chirp_id is null;
+
+-- +goose Down
+drop index attachments_unattached_idx;
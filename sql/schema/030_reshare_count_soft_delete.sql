@@ -0,0 +1,98 @@
+-- +goose Up
+-- rechirp_count and quote_count only count reshares that aren't deleted.
+-- deleting and restoring a chirp is an update of deleted_at, so the counts
+-- follow that too, and a deleted reshare that is purged later has already
+-- been taken off
+-- +goose StatementBegin
+create or replace function update_chirp_reshare_count() returns trigger as $$
+declare
+    original uuid;
+    kind text;
+    delta integer;
+begin
+    if tg_op = 'INSERT' and new.deleted_at is null then
+        original := new.reshare_of;
+        kind := new.reshare_kind;
+        delta := 1;
+    elsif tg_op = 'DELETE' and old.deleted_at is null then
+        original := old.reshare_of;
+        kind := old.reshare_kind;
+        delta := -1;
+    elsif tg_op = 'UPDATE' and old.deleted_at is null and new.deleted_at is not null then
+        original := new.reshare_of;
+        kind := new.reshare_kind;
+        delta := -1;
+    elsif tg_op = 'UPDATE' and old.deleted_at is not null and new.deleted_at is null then
+        original := new.reshare_of;
+        kind := new.reshare_kind;
+        delta := 1;
+    else
+        return null;
+    end if;
+
+    if kind = 'rechirp' then
+        update chirps set rechirp_count = rechirp_count + delta where id = original;
+    elsif kind = 'quote' then
+        update chirps set quote_count = quote_count + delta where id = original;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+drop trigger chirps_reshare_count on chirps;
+create trigger chirps_reshare_count
+after insert or delete or update of deleted_at on chirps
+for each row execute function update_chirp_reshare_count();
+
+-- reshares deleted before this were still counted
+update chirps set
+    rechirp_count = (
+        select count(*) from chirps reshares
+        where reshares.reshare_of = chirps.id
+            and reshares.reshare_kind = 'rechirp'
+            and reshares.deleted_at is null
+    ),
+    quote_count = (
+        select count(*) from chirps reshares
+        where reshares.reshare_of = chirps.id
+            and reshares.reshare_kind = 'quote'
+            and reshares.deleted_at is null
+    );
+
+-- +goose Down
+-- +goose StatementBegin
+create or replace function update_chirp_reshare_count() returns trigger as $$
+begin
+    if tg_op = 'INSERT' then
+        if new.reshare_kind = 'rechirp' then
+            update chirps set rechirp_count = rechirp_count + 1 where id = new.reshare_of;
+        elsif new.reshare_kind = 'quote' then
+            update chirps set quote_count = quote_count + 1 where id = new.reshare_of;
+        end if;
+    else
+        if old.reshare_kind = 'rechirp' then
+            update chirps set rechirp_count = rechirp_count - 1 where id = old.reshare_of;
+        elsif old.reshare_kind = 'quote' then
+            update chirps set quote_count = quote_count - 1 where id = old.reshare_of;
+        end if;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+drop trigger chirps_reshare_count on chirps;
+create trigger chirps_reshare_count
+after insert or delete on chirps
+for each row execute function update_chirp_reshare_count();
+
+update chirps set
+    rechirp_count = (
+        select count(*) from chirps reshares
+        where reshares.reshare_of = chirps.id and reshares.reshare_kind = 'rechirp'
+    ),
+    quote_count = (
+        select count(*) from chirps reshares
+        where reshares.reshare_of = chirps.id and reshares.reshare_kind = 'quote'
+    );
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/chirptext"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+// indexChirpText replaces the hashtags and mentions stored for a chirp with
+// the ones in its body. Run it in the same transaction that saves the body.
+func indexChirpText(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, body string) error {
+	err := qtx.DeleteChirpHashtags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteChirpMentions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	if tags := chirptext.Hashtags(body); len(tags) > 0 {
+		err = qtx.AddChirpHashtags(ctx, database.AddChirpHashtagsParams{
+			ChirpID: chirpID,
+			Tags:    tags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if handles := chirptext.Mentions(body); len(handles) > 0 {
+		err = qtx.AddChirpMentions(ctx, database.AddChirpMentionsParams{
+			ChirpID: chirpID,
+			Handles: handles,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleGetHashtagChirps(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	tag := chirptext.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		errData := makeChirpError("tag is required")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.db.ListHashtagChirps(r.Context(), database.ListHashtagChirpsParams{
+		Tag:            tag,
+		AfterCreatedAt: page.afterCreatedAt,
+		AfterID:        page.afterID,
+		RowLimit:       int32(page.limit + 1),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	writeChirpPage(w, r, cfg, nil, chirps, page.limit)
+}
+
+func handleGetUserMentions(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.db.ListMentionChirps(r.Context(), database.ListMentionChirpsParams{
+		UserID:         userID,
+		AfterCreatedAt: page.afterCreatedAt,
+		AfterID:        page.afterID,
+		RowLimit:       int32(page.limit + 1),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	writeChirpPage(w, r, cfg, nil, chirps, page.limit)
+}
+
+// handleUpdateHandle sets the name other users mention. An empty handle
+// clears it.
+func handleUpdateHandle(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type HandleRequest struct {
+		Handle string `json:"handle"`
+	}
+
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	var handleRequest HandleRequest
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&handleRequest)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	handle := sql.NullString{}
+	if name := strings.TrimPrefix(strings.TrimSpace(handleRequest.Handle), "@"); name != "" {
+		if !chirptext.ValidHandle(name) {
+			errData := makeChirpError("handle must be 3 to 30 letters, digits or underscores")
+			makeJsonResponse(w, errData, http.StatusBadRequest)
+			return
+		}
+		handle = sql.NullString{String: name, Valid: true}
+	}
+
+	dbUser, err := cfg.db.UpdateUserHandle(r.Context(), database.UpdateUserHandleParams{
+		ID:     userID,
+		Handle: handle,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		errData := makeChirpError("handle is taken")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	data, err := json.Marshal(convertUser(dbUser))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+// the same message is used for unknown emails and wrong passwords so a
+// failed login doesn't reveal whether the account exists
+const loginFailedMessage = "incorrect email or password"
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(r *http.Request, cfg *apiConfig) string {
+	return "ip:" + clientIP(r, cfg)
+}
+
+func passwordResetThrottleKey(r *http.Request, cfg *apiConfig) string {
+	return "password-reset:" + clientIP(r, cfg)
+}
+
+func clientIP(r *http.Request, cfg *apiConfig) string {
+	if cfg.trustForwardedFor {
+		// the proxy in front of us appends the address it saw last
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		ip := strings.TrimSpace(forwarded[len(forwarded)-1])
+		if ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// loginLockedFor returns how much longer logins are blocked for any of the
+// given throttle keys, or zero if none of them are locked.
+func loginLockedFor(ctx context.Context, cfg *apiConfig, keys ...string) (time.Duration, error) {
+	var lockedFor time.Duration
+	for _, key := range keys {
+		throttle, err := cfg.db.GetLoginThrottle(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if throttle.LockedUntil.Valid {
+			remaining := time.Until(throttle.LockedUntil.Time)
+			if remaining > lockedFor {
+				lockedFor = remaining
+			}
+		}
+	}
+	return lockedFor, nil
+}
+
+func recordLoginFailure(ctx context.Context, cfg *apiConfig, key string, policy auth.LockoutPolicy) {
+	throttle, err := cfg.db.RecordLoginFailure(ctx, key)
+	if err != nil {
+		fmt.Println("unable to record login failure")
+		fmt.Println(err)
+		return
+	}
+
+	duration := policy.Duration(throttle.Failures)
+	if duration == 0 {
+		return
+	}
+	err = cfg.db.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+		ThrottleKey: key,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(duration), Valid: true},
+	})
+	if err != nil {
+		fmt.Println("unable to lock login")
+		fmt.Println(err)
+	}
+}
+
+func respondLoginLocked(w http.ResponseWriter, lockedFor time.Duration) {
+	respondThrottled(w, lockedFor, "too many failed login attempts, try again later")
+}
+
+func respondThrottled(w http.ResponseWriter, lockedFor time.Duration, message string) {
+	seconds := int(lockedFor.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	errData := makeChirpError(message)
+	makeJsonResponse(w, errData, http.StatusTooManyRequests)
+}
+
+func handleUnlockUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	err = cfg.db.ClearLoginThrottle(r.Context(), accountThrottleKey(user.Email))
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+)
+
+const (
+	defaultTrashRetention = time.Hour * 24 * 30
+	purgeBatchSize        = 100
+)
+
+// TrashedChirp is a deleted chirp its author can still restore, until
+// PurgeAt.
+type TrashedChirp struct {
+	Chirp
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// TrashPage is one page of the trash, paged like ChirpPage.
+type TrashPage struct {
+	Chirps     []TrashedChirp `json:"chirps"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func handleGetTrash(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	page, ok := parseChirpPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the cursor is the time of the delete, not of the chirp
+	dbChirps, err := cfg.db.ListTrash(r.Context(), database.ListTrashParams{
+		UserID:          userID,
+		DeletedSince:    time.Now().UTC().Add(-cfg.trashRetention),
+		BeforeDeletedAt: page.afterCreatedAt,
+		BeforeID:        page.afterID,
+		RowLimit:        int32(page.limit + 1),
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	trash := TrashPage{Chirps: []TrashedChirp{}}
+	if len(dbChirps) > page.limit {
+		dbChirps = dbChirps[:page.limit]
+		last := dbChirps[len(dbChirps)-1]
+		trash.NextCursor = encodeChirpCursor(database.Chirp{ID: last.ID, CreatedAt: last.DeletedAt.Time})
+	}
+	for _, dbChirp := range dbChirps {
+		chirp := convertChirp(dbChirp)
+		// the author still gets to see what they deleted
+		chirp.Body = dbChirp.Body
+		trash.Chirps = append(trash.Chirps, TrashedChirp{
+			Chirp:     chirp,
+			DeletedAt: dbChirp.DeletedAt.Time,
+			PurgeAt:   dbChirp.DeletedAt.Time.Add(cfg.trashRetention),
+		})
+	}
+
+	data, err := json.Marshal(trash)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+func handleRestoreChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	principal, err := auth.PrincipalFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// locked so the purge can't take it while it is being restored
+	chirp, err := qtx.GetChirpByIdForUpdate(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || !chirp.DeletedAt.Valid || chirp.TombstonedAt.Valid {
+		errData := makeChirpError("chirp not found in trash")
+		makeJsonResponse(w, errData, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	// moderators can undo any delete, authors only their own. a chirp a
+	// moderator took down stays down
+	if !principal.HasScopes(auth.ScopeChirpsModerate) {
+		if chirp.UserID != principal.UserID {
+			errData := makeChirpError("user is not the author")
+			makeJsonResponse(w, errData, http.StatusForbidden)
+			return
+		}
+		if chirp.DeletedBy.UUID != principal.UserID {
+			errData := makeChirpError("chirp was removed by a moderator")
+			makeJsonResponse(w, errData, http.StatusForbidden)
+			return
+		}
+	}
+	if chirp.ReshareKind.String == reshareRechirp {
+		errData := makeChirpError("a rechirp comes back when the chirp it reshared is restored")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+	if time.Since(chirp.DeletedAt.Time) > cfg.trashRetention {
+		errData := makeChirpError("chirp has been in the trash too long to restore")
+		makeJsonResponse(w, errData, http.StatusGone)
+		return
+	}
+
+	restored, err := qtx.RestoreChirp(r.Context(), chirp.ID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	err = qtx.RestoreRechirpsOf(r.Context(), database.RestoreRechirpsOfParams{
+		ReshareOf: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		DeletedAt: chirp.DeletedAt,
+	})
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	chirps := []Chirp{convertChirp(restored)}
+	decorateChirps(r, cfg, chirps)
+
+	data, err := json.Marshal(chirps[0])
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+	makeJsonResponse(w, data, http.StatusOK)
+}
+
+// purgeDeletedChirps removes a batch of chirps that have been in the trash
+// longer than the retention window. It returns how many it purged, fewer
+// than purgeBatchSize once there are none left.
+func purgeDeletedChirps(ctx context.Context, cfg *apiConfig) (int, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirps, err := qtx.ClaimPurgeableChirps(ctx, database.ClaimPurgeableChirpsParams{
+		Cutoff:   time.Now().UTC().Add(-cfg.trashRetention),
+		RowLimit: purgeBatchSize,
+	})
+	if err != nil || len(chirps) == 0 {
+		return 0, err
+	}
+
+	// the rows go now and the files once the purge has committed
+	attachments := []database.Attachment{}
+	for _, chirp := range chirps {
+		chirpAttachments, err := purgeChirp(ctx, qtx, chirp)
+		if err != nil {
+			return 0, err
+		}
+		attachments = append(attachments, chirpAttachments...)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	deleteBlobs(ctx, cfg, attachments)
+	return len(chirps), nil
+}
+
+// purgeChirp deletes a chirp for good and returns the attachments whose
+// files have to go too. A chirp with replies is emptied out instead, so the
+// conversation under it stays in one piece.
+func purgeChirp(ctx context.Context, qtx *database.Queries, chirp database.Chirp) ([]database.Attachment, error) {
+	hasReplies, err := qtx.ChirpHasReplies(ctx, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := qtx.DeleteChirpAttachments(ctx, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if hasReplies {
+		err = qtx.TombstoneChirp(ctx, chirp.ID)
+		if err == nil {
+			err = qtx.DeleteChirpRevisions(ctx, chirp.ID)
+		}
+		if err == nil {
+			err = indexChirpText(ctx, qtx, chirp.ID, "")
+		}
+		if err == nil {
+			err = qtx.DeletePoll(ctx, chirp.ID)
+		}
+	} else {
+		err = qtx.DeleteChirp(ctx, chirp.ID)
+	}
+	return attachments, err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mrjkey/chirpy/internal/auth"
+	"github.com/mrjkey/chirpy/internal/database"
+	"github.com/mrjkey/chirpy/internal/mailer"
+)
+
+const emailVerificationDuration = time.Hour * 24
+
+// sendVerificationEmail emails a link that confirms the user's current
+// address. Links sent for any earlier address stop working.
+func sendVerificationEmail(ctx context.Context, cfg *apiConfig, user database.User) error {
+	token, err := auth.MakeEmailToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.InvalidateEmailVerificationTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashEmailToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(emailVerificationDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Chirpy email address",
+		Body: fmt.Sprintf("Confirm this is your email address by opening this link within the next day:\n"+
+			"%v/api/users/verify?token=%v\n", cfg.baseURL, url.QueryEscape(token)),
+	}
+	sendMailInBackground(cfg, msg)
+	return nil
+}
+
+func handleVerifyEmail(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		errData := makeChirpError("missing verification token")
+		makeJsonResponse(w, errData, http.StatusBadRequest)
+		return
+	}
+
+	verification, err := cfg.db.UseEmailVerificationToken(r.Context(), auth.HashEmailToken(token))
+	if err != nil {
+		errData := makeChirpError("verification token is invalid or has expired")
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	// the user may have changed their email again since the link was sent
+	_, err = cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if err != nil {
+		errData := makeChirpError("verification token is invalid or has expired")
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Your email address has been confirmed.\n"))
+}
+
+func handleResendVerification(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	userID, err := auth.UserFromContext(r.Context())
+	if err != nil {
+		errData := makeChirpError(err.Error())
+		makeJsonResponse(w, errData, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		errData := makeChirpError("email is already verified")
+		makeJsonResponse(w, errData, http.StatusConflict)
+		return
+	}
+
+	err = sendVerificationEmail(r.Context(), cfg, user)
+	if err != nil {
+		quickChirpError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}